@@ -0,0 +1,177 @@
+package client
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+	"github.com/v2pro/plz/countlog"
+	"github.com/v2pro/quoll/discr"
+	"sync"
+)
+
+// BufferConfig sizes the in process buffer, the zero fields take the default
+type BufferConfig struct {
+	// Capacity is the number of events waiting to be sent, more events are dropped
+	Capacity int
+	// BatchSize is the number of events sent by one add-events request
+	BatchSize int
+	// FlushInterval is the longest time an event waits for the batch to fill up
+	FlushInterval time.Duration
+}
+
+var defaultBufferConfig = BufferConfig{
+	Capacity:      10240,
+	BatchSize:     256,
+	FlushInterval: time.Second,
+}
+
+// BufferStats counts the events lost by the buffer
+type BufferStats struct {
+	// Dropped when the buffer is full or closed
+	Dropped uint64
+	// Failed to be added by leaf, after retry
+	Failed uint64
+}
+
+// Buffer sends the events in batches from a background goroutine, so that the callers never block on leaf
+type Buffer struct {
+	// accessed atomically, kept first for 64-bit alignment
+	stats  BufferStats
+	client *Client
+	config BufferConfig
+	// addLock is held by Add around the send to events, and by Close around closing stopping
+	addLock  sync.RWMutex
+	closed   bool
+	events   chan discr.EventBody
+	stopping chan struct{}
+	stopped  chan struct{}
+	// sendCtx is cancelled when Close gives up waiting, to abort the request in flight
+	sendCtx    context.Context
+	cancelSend context.CancelFunc
+}
+
+// NewBuffer starts the goroutine sending the buffered events through the client, Close to stop it
+func (client *Client) NewBuffer(config BufferConfig) *Buffer {
+	if config.Capacity == 0 {
+		config.Capacity = defaultBufferConfig.Capacity
+	}
+	if config.BatchSize == 0 {
+		config.BatchSize = defaultBufferConfig.BatchSize
+	}
+	if config.FlushInterval == 0 {
+		config.FlushInterval = defaultBufferConfig.FlushInterval
+	}
+	sendCtx, cancelSend := context.WithCancel(context.Background())
+	buffer := &Buffer{
+		client:     client,
+		config:     config,
+		events:     make(chan discr.EventBody, config.Capacity),
+		stopping:   make(chan struct{}),
+		stopped:    make(chan struct{}),
+		sendCtx:    sendCtx,
+		cancelSend: cancelSend,
+	}
+	go buffer.loop()
+	return buffer
+}
+
+// Add queues the event without waiting, false if it is dropped
+func (buffer *Buffer) Add(eventBody discr.EventBody) bool {
+	buffer.addLock.RLock()
+	defer buffer.addLock.RUnlock()
+	if buffer.closed {
+		atomic.AddUint64(&buffer.stats.Dropped, 1)
+		return false
+	}
+	select {
+	case buffer.events <- eventBody:
+		return true
+	default:
+		atomic.AddUint64(&buffer.stats.Dropped, 1)
+		return false
+	}
+}
+
+// Stats reads the counters of lost events
+func (buffer *Buffer) Stats() BufferStats {
+	return BufferStats{
+		Dropped: atomic.LoadUint64(&buffer.stats.Dropped),
+		Failed:  atomic.LoadUint64(&buffer.stats.Failed),
+	}
+}
+
+// Close stops accepting Add and sends the buffered events, waiting until done or the context expired.
+// If the context expired, the request being sent is cancelled and the events not sent yet are failed.
+func (buffer *Buffer) Close(ctx context.Context) error {
+	buffer.addLock.Lock()
+	if !buffer.closed {
+		buffer.closed = true
+		close(buffer.stopping)
+	}
+	buffer.addLock.Unlock()
+	select {
+	case <-buffer.stopped:
+		buffer.cancelSend()
+		return nil
+	case <-ctx.Done():
+		buffer.cancelSend()
+		return ctx.Err()
+	}
+}
+
+func (buffer *Buffer) loop() {
+	defer close(buffer.stopped)
+	batch := make([]discr.EventBody, 0, buffer.config.BatchSize)
+	timer := time.NewTimer(buffer.config.FlushInterval)
+	defer timer.Stop()
+	for {
+		select {
+		case eventBody := <-buffer.events:
+			batch = append(batch, eventBody)
+			if len(batch) < buffer.config.BatchSize {
+				continue
+			}
+		case <-timer.C:
+			timer.Reset(buffer.config.FlushInterval)
+		case <-buffer.stopping:
+			buffer.drain(batch)
+			return
+		}
+		buffer.send(batch)
+		batch = batch[:0]
+	}
+}
+
+// drain sends what is left in buffer after stopped
+func (buffer *Buffer) drain(batch []discr.EventBody) {
+	for {
+		select {
+		case eventBody := <-buffer.events:
+			batch = append(batch, eventBody)
+			if len(batch) >= buffer.config.BatchSize {
+				buffer.send(batch)
+				batch = batch[:0]
+			}
+		default:
+			buffer.send(batch)
+			return
+		}
+	}
+}
+
+func (buffer *Buffer) send(batch []discr.EventBody) {
+	if len(batch) == 0 {
+		return
+	}
+	result, err := buffer.client.AddEvents(buffer.sendCtx, batch)
+	if err != nil {
+		atomic.AddUint64(&buffer.stats.Failed, uint64(len(batch)))
+		countlog.Error("event!client.failed to add events", "err", err, "count", len(batch))
+		return
+	}
+	if result.Rejected > 0 {
+		atomic.AddUint64(&buffer.stats.Failed, uint64(result.Rejected))
+		countlog.Warn("event!client.events_rejected", "count", result.Rejected,
+			"firstErr", result.Errors[0].Errmsg)
+	}
+}
@@ -0,0 +1,466 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+	"github.com/json-iterator/go"
+	"github.com/v2pro/quoll/discr"
+	"github.com/v2pro/quoll/evtstore"
+)
+
+// Client talks to the http api of leaf, the events are added to the store chosen by WithStore
+type Client struct {
+	baseUrl         string
+	store           string
+	httpClient      *http.Client
+	maxRetries      int
+	retryBackoff    time.Duration
+	maxRetryBackoff time.Duration
+}
+
+type Option func(client *Client)
+
+// WithHttpClient replaces http.DefaultClient, such as to set the timeout
+func WithHttpClient(httpClient *http.Client) Option {
+	return func(client *Client) {
+		client.httpClient = httpClient
+	}
+}
+
+// WithStore selects the named store of leaf instead of the default one
+func WithStore(store string) Option {
+	return func(client *Client) {
+		client.store = store
+	}
+}
+
+// WithRetry retries the add rejected by input queue overflow, waiting from backoff and doubling up to maxBackoff.
+// Zero maxRetries disables the retry.
+func WithRetry(maxRetries int, backoff time.Duration, maxBackoff time.Duration) Option {
+	return func(client *Client) {
+		client.maxRetries = maxRetries
+		client.retryBackoff = backoff
+		client.maxRetryBackoff = maxBackoff
+	}
+}
+
+// NewClient connects to leaf at baseUrl such as http://127.0.0.1:8005
+func NewClient(baseUrl string, options ...Option) *Client {
+	client := &Client{
+		baseUrl:         baseUrl,
+		httpClient:      http.DefaultClient,
+		maxRetries:      3,
+		retryBackoff:    100 * time.Millisecond,
+		maxRetryBackoff: 2 * time.Second,
+	}
+	for _, option := range options {
+		option(client)
+	}
+	return client
+}
+
+// Error is the failure reported by leaf with non-zero errno
+type Error struct {
+	Errno  int
+	Errmsg string
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("leaf error %d: %s", err.Errno, err.Errmsg)
+}
+
+// IsOverflow tells if the leaf rejected the event because its input queue is full
+func IsOverflow(err error) bool {
+	leafErr, ok := err.(*Error)
+	return ok && leafErr.Errmsg == evtstore.ErrInputQueueOverflow.Error()
+}
+
+// AddEvent adds the event happened now
+func (client *Client) AddEvent(ctx context.Context, eventBody discr.EventBody) error {
+	return client.addEvent(ctx, "", eventBody)
+}
+
+// AddEventAt adds the event with the timestamp given by client, late event is saved into its own file
+func (client *Client) AddEventAt(ctx context.Context, eventTS time.Time, eventBody discr.EventBody) error {
+	return client.addEvent(ctx, eventTS.Format(time.RFC3339Nano), eventBody)
+}
+
+func (client *Client) addEvent(ctx context.Context, eventTS string, eventBody discr.EventBody) error {
+	return client.retry(ctx, func() error {
+		req, err := client.newRequest(ctx, "POST", "/add-event", nil, bytes.NewReader(eventBody))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if eventTS != "" {
+			req.Header.Set("X-Event-Timestamp", eventTS)
+		}
+		var resp response
+		return client.do(req, &resp)
+	})
+}
+
+// AddEventsError locates the event rejected in the batch
+type AddEventsError struct {
+	Index  int
+	Errmsg string
+}
+
+// AddEventsResult counts the events added by AddEvents, the rejected ones are listed in Errors
+type AddEventsResult struct {
+	Accepted int
+	Rejected int
+	Errors   []AddEventsError
+}
+
+// AddEvents adds the events in one request. The events rejected by input queue overflow are retried,
+// the other rejected ones are reported in the result.
+func (client *Client) AddEvents(ctx context.Context, eventBodies []discr.EventBody) (*AddEventsResult, error) {
+	result := &AddEventsResult{}
+	pending := make([]int, len(eventBodies)) // index in eventBodies of the events not added yet
+	for i := range pending {
+		pending[i] = i
+	}
+	backoff := client.retryBackoff
+	for retried := 0; ; retried++ {
+		batch := make([]discr.EventBody, len(pending))
+		for i, index := range pending {
+			batch[i] = eventBodies[index]
+		}
+		resp, err := client.addEvents(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		result.Accepted += resp.Accepted
+		var overflowed []int
+		for _, itemErr := range resp.Errors {
+			index := pending[itemErr.Index]
+			if itemErr.Errmsg == evtstore.ErrInputQueueOverflow.Error() && retried < client.maxRetries {
+				overflowed = append(overflowed, index)
+				continue
+			}
+			result.Rejected++
+			result.Errors = append(result.Errors, AddEventsError{Index: index, Errmsg: itemErr.Errmsg})
+		}
+		if len(overflowed) == 0 {
+			return result, nil
+		}
+		if err := sleep(ctx, backoff); err != nil {
+			return nil, err
+		}
+		backoff = client.nextBackoff(backoff)
+		pending = overflowed
+	}
+}
+
+type addEventsResponse struct {
+	response
+	Accepted int
+	Rejected int
+	Errors   []AddEventsError
+}
+
+func (client *Client) addEvents(ctx context.Context, eventBodies []discr.EventBody) (*addEventsResponse, error) {
+	size := 0
+	for _, eventBody := range eventBodies {
+		size += 4 + len(eventBody)
+	}
+	batch := make([]byte, 0, size)
+	var sizeBuf [4]byte
+	for _, eventBody := range eventBodies {
+		binary.LittleEndian.PutUint32(sizeBuf[:], uint32(len(eventBody)))
+		batch = append(batch, sizeBuf[:]...)
+		batch = append(batch, eventBody...)
+	}
+	req, err := client.newRequest(ctx, "POST", "/add-events", nil, bytes.NewReader(batch))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp := &addEventsResponse{}
+	err = client.do(req, resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ListQuery selects the blocks to list, the zero StartTime and EndTime are left to leaf (the last hour)
+type ListQuery struct {
+	StartTime time.Time
+	EndTime   time.Time
+	Skip      int
+	Limit     int
+	// Cursor is the NextCursor of previous page
+	Cursor evtstore.Cursor
+}
+
+// ListResult holds the blocks in the wire format of evtstore.EventBlocks
+type ListResult struct {
+	Blocks     evtstore.EventBlocks
+	NextCursor evtstore.Cursor
+}
+
+// ListEvents lists the blocks overlapping with the time range
+func (client *Client) ListEvents(ctx context.Context, query ListQuery) (*ListResult, error) {
+	params := url.Values{}
+	if !query.StartTime.IsZero() {
+		params.Set("startTime", query.StartTime.Format(time.RFC3339Nano))
+	}
+	if !query.EndTime.IsZero() {
+		params.Set("endTime", query.EndTime.Format(time.RFC3339Nano))
+	}
+	if query.Skip != 0 {
+		params.Set("skip", strconv.Itoa(query.Skip))
+	}
+	if query.Limit != 0 {
+		params.Set("limit", strconv.Itoa(query.Limit))
+	}
+	if query.Cursor != nil {
+		params.Set("cursor", base64.RawURLEncoding.EncodeToString(query.Cursor))
+	}
+	req, err := client.newRequest(ctx, "GET", "/list-events", params, nil)
+	if err != nil {
+		return nil, err
+	}
+	httpResp, err := client.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+	body, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, err
+	}
+	// the error is responded in json, while the blocks start with the file name of digits
+	if len(body) > 0 && body[0] == '{' {
+		var resp response
+		if err := jsoniter.Unmarshal(body, &resp); err != nil {
+			return nil, err
+		}
+		return nil, resp.err()
+	}
+	result := &ListResult{Blocks: evtstore.EventBlocks(body)}
+	nextCursor := httpResp.Header.Get("X-Next-Cursor")
+	if nextCursor != "" {
+		cursorBytes, err := base64.RawURLEncoding.DecodeString(nextCursor)
+		if err != nil {
+			return nil, err
+		}
+		result.NextCursor, err = evtstore.ParseCursor(cursorBytes)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// SceneStatsQuery selects the scene counts, the zero StartTime and EndTime are left to leaf (the last hour).
+// SessionType and Scene are optional, the scene is selected if it has all the values of Scene.
+type SceneStatsQuery struct {
+	StartTime   time.Time
+	EndTime     time.Time
+	SessionType string
+	Scene       map[string]string
+}
+
+// SceneStatsResult sums up Seen and Kept of the selected scene counts
+type SceneStatsResult struct {
+	SceneStats []evtstore.SceneStats
+	Seen       int
+	Kept       int
+}
+
+// ListSceneStats tells how often the scenes occurred in the windows overlapping with the time range,
+// including the sessions dropped by the sampling
+func (client *Client) ListSceneStats(ctx context.Context, query SceneStatsQuery) (*SceneStatsResult, error) {
+	params := url.Values{}
+	if !query.StartTime.IsZero() {
+		params.Set("startTime", query.StartTime.Format(time.RFC3339Nano))
+	}
+	if !query.EndTime.IsZero() {
+		params.Set("endTime", query.EndTime.Format(time.RFC3339Nano))
+	}
+	if query.SessionType != "" {
+		params.Set("sessionType", query.SessionType)
+	}
+	if len(query.Scene) > 0 {
+		scene, err := jsoniter.Marshal(query.Scene)
+		if err != nil {
+			return nil, err
+		}
+		params.Set("scene", string(scene))
+	}
+	req, err := client.newRequest(ctx, "GET", "/list-scene-stats", params, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp := &sceneStatsResponse{}
+	err = client.do(req, resp)
+	if err != nil {
+		return nil, err
+	}
+	return &resp.SceneStatsResult, nil
+}
+
+type sceneStatsResponse struct {
+	response
+	SceneStatsResult
+}
+
+// UpdateSessionMatcher replaces the matcher of the session type
+func (client *Client) UpdateSessionMatcher(ctx context.Context, cnf discr.SessionMatcherCnf) error {
+	body, err := jsoniter.Marshal(cnf)
+	if err != nil {
+		return err
+	}
+	req, err := client.newRequest(ctx, "POST", "/update-session-matcher", nil, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	var resp response
+	return client.do(req, &resp)
+}
+
+// response is the json responded by leaf apis other than list-events
+type response struct {
+	Errno  int
+	Errmsg string
+}
+
+func (resp *response) err() error {
+	if resp.Errno == 0 {
+		return nil
+	}
+	return &Error{Errno: resp.Errno, Errmsg: resp.Errmsg}
+}
+
+type erroneous interface {
+	err() error
+}
+
+func (client *Client) newRequest(ctx context.Context, method string, api string,
+	params url.Values, body *bytes.Reader) (*http.Request, error) {
+	if client.store != "" {
+		if params == nil {
+			params = url.Values{}
+		}
+		params.Set("store", client.store)
+	}
+	reqUrl := client.baseUrl + api
+	if len(params) > 0 {
+		reqUrl += "?" + params.Encode()
+	}
+	var req *http.Request
+	var err error
+	if body == nil {
+		req, err = http.NewRequest(method, reqUrl, nil)
+	} else {
+		req, err = http.NewRequest(method, reqUrl, body)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return req.WithContext(ctx), nil
+}
+
+func (client *Client) do(req *http.Request, resp erroneous) error {
+	httpResp, err := client.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+	body, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return err
+	}
+	if err := jsoniter.Unmarshal(body, resp); err != nil {
+		return fmt.Errorf("unexpected response of status %d: %s", httpResp.StatusCode, body)
+	}
+	return resp.err()
+}
+
+// retry calls the request again when rejected by input queue overflow
+func (client *Client) retry(ctx context.Context, request func() error) error {
+	backoff := client.retryBackoff
+	for retried := 0; ; retried++ {
+		err := request()
+		if !IsOverflow(err) || retried >= client.maxRetries {
+			return err
+		}
+		if err := sleep(ctx, backoff); err != nil {
+			return err
+		}
+		backoff = client.nextBackoff(backoff)
+	}
+}
+
+func (client *Client) nextBackoff(backoff time.Duration) time.Duration {
+	backoff *= 2
+	if backoff > client.maxRetryBackoff {
+		return client.maxRetryBackoff
+	}
+	return backoff
+}
+
+func sleep(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Event is decoded from the blocks listed. The timestamp is compressed relative to the file
+// of the block, which is not known to the client, use get-block with format=events to get it.
+type Event struct {
+	BlockId  evtstore.EventBlockId
+	EventCTS uint32
+	Body     discr.EventBody
+}
+
+// DecodeEvents decompresses the listed blocks into events, in the order they are listed
+func DecodeEvents(blocks evtstore.EventBlocks) ([]Event, error) {
+	var events []Event
+	for len(blocks) > 0 {
+		if len(blocks) < blockHeaderEnd ||
+			len(blocks) < blockHeaderEnd+int(binary.LittleEndian.Uint32(blocks[blockIdSize:])) {
+			return nil, errors.New("truncated event blocks")
+		}
+		var blockId evtstore.EventBlockId
+		var block evtstore.EventBlock
+		blockId, block, blocks = blocks.Next()
+		entries, err := block.Decompress()
+		if err != nil {
+			return nil, err
+		}
+		for len(entries) > 0 {
+			var entry evtstore.EventEntry
+			entry, entries = entries.Next()
+			events = append(events, Event{
+				BlockId:  blockId,
+				EventCTS: entry.EventCTS(),
+				Body:     entry.EventBody(),
+			})
+		}
+	}
+	return events, nil
+}
+
+const blockIdSize = 20
+
+// blockHeaderEnd is the size of block id and block header, before the compressed entries
+const blockHeaderEnd = blockIdSize + 18
@@ -0,0 +1,261 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+	"github.com/blang/vfs/memfs"
+	"github.com/stretchr/testify/require"
+	"github.com/v2pro/quoll/discr"
+	"github.com/v2pro/quoll/evtstore"
+)
+
+func init() {
+	// keep every event, instead of one per scene
+	discr.NewDiscrminator = func() discr.Discrminator {
+		return &mockDiscr{}
+	}
+}
+
+type mockDiscr struct {
+}
+
+func (md *mockDiscr) SceneOf(eventBody discr.EventBody) discr.Scene {
+	return discr.Scene{}
+}
+
+func newTestClient(handler http.HandlerFunc) (*Client, *httptest.Server) {
+	server := httptest.NewServer(handler)
+	return NewClient(server.URL, WithRetry(3, time.Millisecond, time.Millisecond)), server
+}
+
+func Test_add_event_retry_on_overflow(t *testing.T) {
+	should := require.New(t)
+	calls := 0
+	client, server := newTestClient(func(respWriter http.ResponseWriter, req *http.Request) {
+		calls++
+		body, _ := ioutil.ReadAll(req.Body)
+		should.Equal(`{"url":"/hello"}`, string(body))
+		should.Equal("2017-01-01T00:00:00Z", req.Header.Get("X-Event-Timestamp"))
+		if calls < 3 {
+			respWriter.Write([]byte(`{"errno":1,"errmsg":"input queue overflow"}`))
+			return
+		}
+		respWriter.Write([]byte(`{"errno":0}`))
+	})
+	defer server.Close()
+	eventTS := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	should.Nil(client.AddEventAt(context.Background(), eventTS, []byte(`{"url":"/hello"}`)))
+	should.Equal(3, calls)
+}
+
+func Test_add_event_error(t *testing.T) {
+	should := require.New(t)
+	calls := 0
+	client, server := newTestClient(func(respWriter http.ResponseWriter, req *http.Request) {
+		calls++
+		respWriter.Write([]byte(`{"errno":1,"errmsg":"store closed"}`))
+	})
+	defer server.Close()
+	err := client.AddEvent(context.Background(), []byte(`{"url":"/hello"}`))
+	should.Equal(&Error{Errno: 1, Errmsg: "store closed"}, err)
+	should.False(IsOverflow(err))
+	should.Equal(1, calls)
+}
+
+func Test_add_events_retry_overflowed(t *testing.T) {
+	should := require.New(t)
+	var batches [][]string
+	client, server := newTestClient(func(respWriter http.ResponseWriter, req *http.Request) {
+		should.Equal("/add-events", req.URL.Path)
+		should.Equal("application/octet-stream", req.Header.Get("Content-Type"))
+		body, _ := ioutil.ReadAll(req.Body)
+		var batch []string
+		for len(body) > 0 {
+			size := binary.LittleEndian.Uint32(body)
+			batch = append(batch, string(body[4:4+size]))
+			body = body[4+size:]
+		}
+		batches = append(batches, batch)
+		if len(batches) == 1 {
+			respWriter.Write([]byte(`{"errno":0,"accepted":1,"rejected":2,"errors":[` +
+				`{"index":1,"errmsg":"input queue overflow"},{"index":2,"errmsg":"store closed"}]}`))
+			return
+		}
+		respWriter.Write([]byte(`{"errno":0,"accepted":1,"rejected":0,"errors":[]}`))
+	})
+	defer server.Close()
+	result, err := client.AddEvents(context.Background(), []discr.EventBody{
+		[]byte(`{"url":"/hello1"}`), []byte(`{"url":"/hello2"}`), []byte(`{"url":"/hello3"}`)})
+	should.Nil(err)
+	should.Equal([][]string{
+		{`{"url":"/hello1"}`, `{"url":"/hello2"}`, `{"url":"/hello3"}`},
+		{`{"url":"/hello2"}`},
+	}, batches)
+	should.Equal(&AddEventsResult{
+		Accepted: 2, Rejected: 1, Errors: []AddEventsError{{Index: 2, Errmsg: "store closed"}},
+	}, result)
+}
+
+func Test_list_events(t *testing.T) {
+	should := require.New(t)
+	fs := memfs.Create()
+	fs.Mkdir("/tmp", 0666)
+	store := evtstore.NewStore("/tmp", evtstore.WithFilesystem(fs))
+	should.Nil(store.AddAll([]discr.EventBody{[]byte(`{"url":"/hello1"}`), []byte(`{"url":"/hello2"}`)}))
+	// the queued events are saved on close
+	should.Nil(store.Close(context.Background()))
+	blocks, cursor, err := store.ListAfter(nil, time.Now().Add(-time.Hour), time.Now(), 0, 10)
+	should.Nil(err)
+	client, server := newTestClient(func(respWriter http.ResponseWriter, req *http.Request) {
+		should.Equal("/list-events", req.URL.Path)
+		should.Equal("orders", req.URL.Query().Get("store"))
+		should.Equal("5", req.URL.Query().Get("limit"))
+		respWriter.Header().Set("X-Next-Cursor", base64.RawURLEncoding.EncodeToString(cursor))
+		respWriter.Write(blocks)
+	})
+	defer server.Close()
+	WithStore("orders")(client)
+	result, err := client.ListEvents(context.Background(), ListQuery{Limit: 5})
+	should.Nil(err)
+	should.Equal(cursor, result.NextCursor)
+	events, err := DecodeEvents(result.Blocks)
+	should.Nil(err)
+	should.Len(events, 2)
+	should.Equal(`{"url":"/hello1"}`, string(events[0].Body))
+	should.Equal(`{"url":"/hello2"}`, string(events[1].Body))
+	_, err = DecodeEvents(result.Blocks[:len(result.Blocks)-1])
+	should.NotNil(err)
+}
+
+func Test_list_events_error(t *testing.T) {
+	should := require.New(t)
+	client, server := newTestClient(func(respWriter http.ResponseWriter, req *http.Request) {
+		respWriter.Write([]byte(`{"errno":1,"errmsg":"unknown store: orders"}`))
+	})
+	defer server.Close()
+	_, err := client.ListEvents(context.Background(), ListQuery{})
+	should.Equal(&Error{Errno: 1, Errmsg: "unknown store: orders"}, err)
+}
+
+func Test_buffer(t *testing.T) {
+	should := require.New(t)
+	var lock sync.Mutex
+	var batchSizes []int
+	client, server := newTestClient(func(respWriter http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		count := 0
+		for len(body) > 0 {
+			body = body[4+binary.LittleEndian.Uint32(body):]
+			count++
+		}
+		lock.Lock()
+		batchSizes = append(batchSizes, count)
+		lock.Unlock()
+		respWriter.Write([]byte(`{"errno":0,"accepted":1,"rejected":0,"errors":[]}`))
+	})
+	defer server.Close()
+	buffer := client.NewBuffer(BufferConfig{Capacity: 3, BatchSize: 2, FlushInterval: time.Hour})
+	// the buffer is not drained by the sending goroutine until the batch fills up
+	for i := 0; i < 5; i++ {
+		buffer.Add([]byte(`{"url":"/hello"}`))
+	}
+	should.Nil(buffer.Close(context.Background()))
+	should.False(buffer.Add([]byte(`{"url":"/hello"}`)))
+	stats := buffer.Stats()
+	lock.Lock()
+	defer lock.Unlock()
+	sent := 0
+	for _, batchSize := range batchSizes {
+		should.True(batchSize <= 2)
+		sent += batchSize
+	}
+	should.Equal(uint64(6), uint64(sent)+stats.Dropped)
+	should.Equal(uint64(0), stats.Failed)
+}
+
+func Test_list_scene_stats(t *testing.T) {
+	should := require.New(t)
+	client, server := newTestClient(func(respWriter http.ResponseWriter, req *http.Request) {
+		should.Equal("/list-scene-stats", req.URL.Path)
+		should.Equal("/order", req.URL.Query().Get("sessionType"))
+		should.Equal(`{"combo_type":"4"}`, req.URL.Query().Get("scene"))
+		respWriter.Write([]byte(`{"errno":0,"sceneStats":[{"WindowStart":"2017-01-01T00:00:00Z","SessionType":"/order",` +
+			`"Scene":{"combo_type":"4"},"Seen":10,"Kept":2}],"seen":10,"kept":2}`))
+	})
+	defer server.Close()
+	result, err := client.ListSceneStats(context.Background(), SceneStatsQuery{
+		SessionType: "/order",
+		Scene:       map[string]string{"combo_type": "4"},
+	})
+	should.Nil(err)
+	should.Equal(10, result.Seen)
+	should.Equal(2, result.Kept)
+	should.Len(result.SceneStats, 1)
+	should.Equal(map[string]string{"combo_type": "4"}, result.SceneStats[0].Scene)
+}
+
+func Test_buffer_add_racing_close(t *testing.T) {
+	should := require.New(t)
+	var lock sync.Mutex
+	sent := 0
+	client, server := newTestClient(func(respWriter http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		lock.Lock()
+		for len(body) > 0 {
+			body = body[4+binary.LittleEndian.Uint32(body):]
+			sent++
+		}
+		lock.Unlock()
+		respWriter.Write([]byte(`{"errno":0,"accepted":1,"rejected":0,"errors":[]}`))
+	})
+	defer server.Close()
+	buffer := client.NewBuffer(BufferConfig{Capacity: 100, BatchSize: 10, FlushInterval: time.Hour})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				buffer.Add([]byte(`{"url":"/hello"}`))
+			}
+		}()
+	}
+	should.Nil(buffer.Close(context.Background()))
+	wg.Wait()
+	lock.Lock()
+	defer lock.Unlock()
+	// every event is either sent or counted as dropped, none left in the buffer
+	should.Equal(uint64(400), uint64(sent)+buffer.Stats().Dropped)
+}
+
+func Test_buffer_close_timeout_cancels_send(t *testing.T) {
+	should := require.New(t)
+	unblock := make(chan struct{})
+	client, server := newTestClient(func(respWriter http.ResponseWriter, req *http.Request) {
+		select {
+		case <-unblock:
+		case <-req.Context().Done():
+		}
+	})
+	defer server.Close()
+	defer close(unblock)
+	buffer := client.NewBuffer(BufferConfig{Capacity: 3, BatchSize: 1, FlushInterval: time.Hour})
+	should.True(buffer.Add([]byte(`{"url":"/hello"}`)))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	should.Equal(context.DeadlineExceeded, buffer.Close(ctx))
+	// the request in flight is aborted, instead of leaking the goroutine
+	select {
+	case <-buffer.stopped:
+	case <-time.After(5 * time.Second):
+		should.Fail("sending goroutine not stopped")
+	}
+	should.Equal(uint64(1), buffer.Stats().Failed)
+}
@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"path"
 	"github.com/v2pro/quoll/evtstore"
+	"encoding/binary"
 )
 
 func Test_list(t *testing.T) {
@@ -58,17 +59,29 @@ func Test_add(t *testing.T) {
 		should.Nil(err)
 		respBody, err := ioutil.ReadAll(resp.Body)
 		should.Nil(err)
-		if string(respBody) != `{"errno":0}` {
-			time.Sleep(time.Second)
-			resp, err := http.Post("http://127.0.0.1:8005/add-event", "application/json", bytes.NewBuffer(content))
-			should.Nil(err)
-			respBody, err := ioutil.ReadAll(resp.Body)
-			should.Nil(err)
-			should.Equal(`{"errno":0}`, string(respBody))
-		}
+		should.Equal(`{"errno":0}`, string(respBody))
 	}
 	after := time.Now()
 	fmt.Println(totalSize)
 	fmt.Println(len(contents))
 	fmt.Println(after.Sub(before))
-}
\ No newline at end of file
+}
+
+func Test_add_events(t *testing.T) {
+	should := require.New(t)
+	batch := []byte{}
+	sizeBuf := []byte{0, 0, 0, 0}
+	for i := 0; i < 1024; i++ {
+		content := []byte(fmt.Sprintf(`{"url":"/hello%d"}`, i))
+		binary.LittleEndian.PutUint32(sizeBuf, uint32(len(content)))
+		batch = append(batch, sizeBuf...)
+		batch = append(batch, content...)
+	}
+	before := time.Now()
+	resp, err := http.Post("http://127.0.0.1:8005/add-events", "application/octet-stream", bytes.NewBuffer(batch))
+	should.Nil(err)
+	respBody, err := ioutil.ReadAll(resp.Body)
+	should.Nil(err)
+	should.Contains(string(respBody), `"accepted":1024`)
+	fmt.Println(time.Now().Sub(before))
+}
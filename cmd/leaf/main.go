@@ -6,22 +6,159 @@ import (
 	"runtime"
 	"github.com/v2pro/quoll/leaf"
 	_ "net/http/pprof"
+	"os"
+	"os/signal"
+	"syscall"
+	"context"
+	"time"
+	"flag"
+	"errors"
+	"strings"
+	"github.com/v2pro/quoll/evtstore"
+	"net"
+	"google.golang.org/grpc"
 )
 
+// shutdownTimeout bounds the time to finish the requests and flush the store
+const shutdownTimeout = 10 * time.Second
+
+// matchersConfigPollInterval is how soon the change of -matchers-config is applied
+const matchersConfigPollInterval = 2 * time.Second
+
+// storeFlags collects -store name=rootDir
+type storeFlags map[string]string
+
+func (flags storeFlags) String() string {
+	return ""
+}
+
+func (flags storeFlags) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return errors.New("expect name=rootDir, got " + value)
+	}
+	flags[parts[0]] = parts[1]
+	return nil
+}
+
 func main() {
+	stores := storeFlags{}
+	flag.Var(stores, "store", "name=rootDir of the store selected by store= parameter, can be repeated")
+	storesConfig := flag.String("stores-config", "",
+		"yaml or json file of the list of stores with their config, such as RotationInterval and MaxAge")
+	defaultStore := flag.String("default-store", "/tmp/store",
+		"root dir of the store used without store= parameter, empty to serve without default store")
+	sessionMatchersFile := flag.String("session-matchers", "",
+		"file to persist the session matchers, default to session_matchers.json in the root dir of default store")
+	matchersConfig := flag.String("matchers-config", "",
+		"yaml or json file of session matchers, replacing all of them and reloaded on change")
+	grpcAddr := flag.String("grpc-addr", ":8006", "address of the grpc service, empty to disable")
+	tailAllowedOrigins := flag.String("tail-allowed-origins", "",
+		"comma separated origins of other web pages allowed to tail by websocket, such as http://dashboard:8080")
+	flag.Parse()
 	runtime.GOMAXPROCS(1)
 	logWriter := countlog.NewAsyncLogWriter(
 		countlog.LEVEL_DEBUG, countlog.NewFileLogOutput("STDERR"))
 	logWriter.EventWhitelist["event!discr.SceneOf"] = true
 	logWriter.Start()
 	countlog.LogWriters = append(countlog.LogWriters, logWriter)
+	leaf.SetDefaultStoreRootDir(*defaultStore)
+	if *storesConfig != "" {
+		err := leaf.LoadStoresConfig(*storesConfig)
+		if err != nil {
+			countlog.Error("event!agent.failed to load stores config", "err", err, "filePath", *storesConfig)
+			return
+		}
+	}
+	for name, rootDir := range stores {
+		store := evtstore.NewStore(rootDir)
+		store.Config.OverflowPolicy = evtstore.OverflowBlock
+		err := leaf.AddStore(name, store)
+		if err != nil {
+			countlog.Error("event!agent.failed to add store", "err", err, "name", name)
+			return
+		}
+	}
+	if *sessionMatchersFile != "" {
+		leaf.SetSessionMatchersFile(*sessionMatchersFile)
+	}
+	if *tailAllowedOrigins != "" {
+		leaf.SetTailAllowedOrigins(strings.Split(*tailAllowedOrigins, ","))
+	}
 	err := leaf.RegisterHttpHandlers(http.DefaultServeMux)
 	if err != nil {
 		countlog.Error("event!agent.start failed", "err", err)
 		return
 	}
+	if *matchersConfig != "" {
+		appliedContent, err := leaf.LoadSessionMatchersConfig(*matchersConfig)
+		if err != nil {
+			countlog.Error("event!agent.failed to load session matchers config", "err", err,
+				"filePath", *matchersConfig)
+			return
+		}
+		watchCtx, stopWatching := context.WithCancel(context.Background())
+		defer stopWatching()
+		go leaf.WatchSessionMatchersConfig(watchCtx, *matchersConfig, appliedContent, matchersConfigPollInterval)
+	}
 	addr := ":8005"
 	countlog.Info("event!agent.start", "addr", addr)
-	err = http.ListenAndServe(addr, http.DefaultServeMux)
-	countlog.Info("event!agent.stop", "err", err)
+	server := &http.Server{Addr: addr, Handler: http.DefaultServeMux}
+	serverErr := make(chan error, 2)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+	var grpcServer *grpc.Server
+	if *grpcAddr != "" {
+		listener, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			countlog.Error("event!agent.failed to listen grpc", "err", err, "addr", *grpcAddr)
+			return
+		}
+		countlog.Info("event!agent.start_grpc", "addr", *grpcAddr)
+		grpcServer = grpc.NewServer()
+		leaf.RegisterGrpcService(grpcServer)
+		go func() {
+			serverErr <- grpcServer.Serve(listener)
+		}()
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	select {
+	case err = <-serverErr:
+		countlog.Info("event!agent.stop", "err", err)
+	case sig := <-signals:
+		countlog.Info("event!agent.stop", "signal", sig.String())
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		err = server.Shutdown(ctx)
+		if err != nil {
+			countlog.Error("event!agent.failed to shutdown http server", "err", err)
+		}
+	}
+	if grpcServer != nil {
+		stopGrpcServer(grpcServer)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err = leaf.Close(ctx)
+	if err != nil {
+		countlog.Error("event!agent.failed to close store", "err", err)
+	}
+}
+
+// stopGrpcServer waits for the calls in flight, the streams such as Tail are cut off after shutdownTimeout
+func stopGrpcServer(grpcServer *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	timer := time.NewTimer(shutdownTimeout)
+	defer timer.Stop()
+	select {
+	case <-stopped:
+	case <-timer.C:
+		grpcServer.Stop()
+	}
 }
\ No newline at end of file
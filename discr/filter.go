@@ -0,0 +1,73 @@
+package discr
+
+// SceneFilterCnf keeps only the tailed sessions satisfying all the predicates, the empty cnf keeps all
+type SceneFilterCnf struct {
+	// SceneEquals requires the scene value of each key, such as product_id => 3
+	SceneEquals map[string]string
+	// SceneMatched requires each key of the matcher under trial to be matched at all
+	SceneMatched []string
+	// SessionPatterns requires each pattern to be found in the raw session, whatever the scene is
+	SessionPatterns map[string]string
+}
+
+type sceneFilter struct {
+	sceneEquals     map[string]string
+	sceneMatched    []string
+	sessionPatterns *patternGroup
+	// sessionPatternsCount is the number of distinct keys to be found
+	sessionPatternsCount int
+}
+
+func newSceneFilter(cnf SceneFilterCnf) (*sceneFilter, error) {
+	if len(cnf.SceneEquals) == 0 && len(cnf.SceneMatched) == 0 && len(cnf.SessionPatterns) == 0 {
+		return nil, nil
+	}
+	sessionPatterns, err := newPatternGroup(cnf.SessionPatterns)
+	if err != nil {
+		return nil, err
+	}
+	return &sceneFilter{
+		sceneEquals:          cnf.SceneEquals,
+		sceneMatched:         cnf.SceneMatched,
+		sessionPatterns:      sessionPatterns,
+		sessionPatternsCount: len(cnf.SessionPatterns),
+	}, nil
+}
+
+// accepts is not thread safe, the scratch of the pattern group is shared
+func (filter *sceneFilter) accepts(tailed TailedSession) bool {
+	if filter == nil {
+		return true
+	}
+	if len(filter.sceneEquals) > 0 || len(filter.sceneMatched) > 0 {
+		if tailed.MatchErr != nil {
+			return false
+		}
+		scene := tailed.Scene.ToMap()
+		for key, value := range filter.sceneEquals {
+			matched, found := scene[key]
+			if !found || matched != value {
+				return false
+			}
+		}
+		for _, key := range filter.sceneMatched {
+			if _, found := scene[key]; !found {
+				return false
+			}
+		}
+	}
+	if filter.sessionPatterns != nil {
+		matches, err := filter.sessionPatterns.match(tailed.Session)
+		if err != nil {
+			return false
+		}
+		found := map[string]bool{}
+		for _, match := range matches {
+			found[string(match.key)] = true
+		}
+		if len(found) < filter.sessionPatternsCount {
+			return false
+		}
+	}
+	return true
+}
@@ -8,71 +8,23 @@ import (
 	"sync"
 	"time"
 	"strings"
+	"sort"
+	"fmt"
+	"math/rand"
 )
 
 type EventBody []byte
 
-type SessionTailer func(string, []byte)
-
-var sessionTailers = map[string]chan SessionTailer{}
-var sessionTailersMutex = &sync.Mutex{}
-
 var sessionMatchers = map[string]*sessionMatcher{}
 var sessionMatchersMutex = &sync.Mutex{}
 
-func AddSessionTailer(sessionType string, sessionTailer SessionTailer) error {
-	{
-		sessionTailersMutex.Lock()
-		defer sessionTailersMutex.Unlock()
-		c := sessionTailers[sessionType]
-		if c == nil {
-			sessionTailers[sessionType] = make(chan SessionTailer, 1024)
-		}
-	}
-	select {
-	case sessionTailers[sessionType] <- sessionTailer:
-		return nil
-	default:
-		return errors.New("overflow")
-	}
-}
-
-func notifySessionTailer(sessionType string, session []byte) {
-	for _, sessionTailer := range getSessionTailer("*") {
-		sessionTailer(sessionType, session)
-	}
-	for _, sessionTailer := range getSessionTailer(sessionType) {
-		sessionTailer(sessionType, session)
-	}
-}
-
-func getSessionTailer(sessionType string) []SessionTailer {
-	var c chan SessionTailer
-	{
-		sessionTailersMutex.Lock()
-		defer sessionTailersMutex.Unlock()
-		c = sessionTailers[sessionType]
-	}
-	if c == nil {
-		return nil
-	}
-	var sessionTailers []SessionTailer
-	for {
-		select {
-		case sessionTailer := <-c:
-			sessionTailers = append(sessionTailers, sessionTailer)
-		default:
-			return sessionTailers
-		}
-	}
-}
-
 type sessionMatcher struct {
-	sessionType           string // url
-	keepNSessionsPerScene int
-	callOutbounds         map[string]*callOutboundMatcher
-	inboundRequestPg      *patternGroup
-	inboundResponsePg     *patternGroup
+	cnf               SessionMatcherCnf // as updated, before the session type escaped
+	sessionType       string            // url
+	sampling          *samplingStrategy
+	callOutbounds     map[string]*callOutboundMatcher
+	inboundRequestPg  *patternGroup
+	inboundResponsePg *patternGroup
 }
 
 type callOutboundMatcher struct {
@@ -84,6 +36,7 @@ type callOutboundMatcher struct {
 type SessionMatcherCnf struct {
 	SessionType             string
 	KeepNSessionsPerScene   int
+	Sampling                SamplingCnf
 	InboundRequestPatterns  map[string]string
 	InboundResponsePatterns map[string]string
 	CallOutbounds           []CallOutboundMatcherCnf
@@ -102,20 +55,114 @@ func getSessionMatcher(sessionType string) *sessionMatcher {
 }
 
 func UpdateSessionMatcher(cnf SessionMatcherCnf) error {
+	sessionMatcher, err := newSessionMatcherOf(cnf)
+	if err != nil {
+		return err
+	}
+	sessionMatchersMutex.Lock()
+	defer sessionMatchersMutex.Unlock()
+	sessionMatchers[sessionMatcher.sessionType] = sessionMatcher
+	return nil
+}
+
+// newSessionMatcherOf compiles the cnf as updated, matching the escaped session type
+func newSessionMatcherOf(cnf SessionMatcherCnf) (*sessionMatcher, error) {
 	if cnf.SessionType == "" {
-		return errors.New("session type is empty")
+		return nil, errors.New("session type is empty")
 	}
-	cnf.SessionType = strings.Replace(cnf.SessionType, `/`, `\/`, -1)
-	sessionMatcher, err := newSessionMatcher(cnf)
+	escapedCnf := cnf
+	escapedCnf.SessionType = escapeSessionType(cnf.SessionType)
+	sessionMatcher, err := newSessionMatcher(escapedCnf)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	sessionMatcher.cnf = cnf
+	return sessionMatcher, nil
+}
+
+// SessionMatcherCnfError tells why the cnf at Index is rejected by ReplaceSessionMatchers
+type SessionMatcherCnfError struct {
+	Index       int
+	SessionType string
+	Err         error
+}
+
+func (err *SessionMatcherCnfError) Error() string {
+	return fmt.Sprintf("session matcher #%d %s: %s", err.Index, err.SessionType, err.Err.Error())
+}
+
+// SessionMatcherCnfErrors collects the error of every rejected cnf
+type SessionMatcherCnfErrors []*SessionMatcherCnfError
+
+func (errs SessionMatcherCnfErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
 	}
+	return strings.Join(msgs, "; ")
+}
+
+// ReplaceSessionMatchers swaps the full set of matchers at once. All the patterns are compiled first,
+// if any cnf is rejected the current set is kept and SessionMatcherCnfErrors is returned.
+func ReplaceSessionMatchers(cnfs []SessionMatcherCnf) error {
+	var errs SessionMatcherCnfErrors
+	newSessionMatchers := map[string]*sessionMatcher{}
+	for i, cnf := range cnfs {
+		sessionMatcher, err := newSessionMatcherOf(cnf)
+		if err == nil && newSessionMatchers[sessionMatcher.sessionType] != nil {
+			err = errors.New("duplicated session type")
+		}
+		if err != nil {
+			errs = append(errs, &SessionMatcherCnfError{Index: i, SessionType: cnf.SessionType, Err: err})
+			continue
+		}
+		newSessionMatchers[sessionMatcher.sessionType] = sessionMatcher
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	sessionMatchersMutex.Lock()
+	defer sessionMatchersMutex.Unlock()
+	sessionMatchers = newSessionMatchers
+	return nil
+}
+
+// DeleteSessionMatcher removes the matcher, the sessions of the type are no longer kept
+func DeleteSessionMatcher(sessionType string) error {
+	escapedSessionType := escapeSessionType(sessionType)
 	sessionMatchersMutex.Lock()
 	defer sessionMatchersMutex.Unlock()
-	sessionMatchers[cnf.SessionType] = sessionMatcher
+	if sessionMatchers[escapedSessionType] == nil {
+		return errors.New("session matcher not found: " + sessionType)
+	}
+	delete(sessionMatchers, escapedSessionType)
 	return nil
 }
 
+// SessionMatcherCnfs lists the config of matchers as they were updated, ordered by session type
+func SessionMatcherCnfs() []SessionMatcherCnf {
+	sessionMatchersMutex.Lock()
+	defer sessionMatchersMutex.Unlock()
+	cnfs := make([]SessionMatcherCnf, 0, len(sessionMatchers))
+	for _, sessionMatcher := range sessionMatchers {
+		cnfs = append(cnfs, sessionMatcher.cnf)
+	}
+	sort.Slice(cnfs, func(i, j int) bool {
+		return cnfs[i].SessionType < cnfs[j].SessionType
+	})
+	return cnfs
+}
+
+// escapeSessionType as the request uri is matched within the json string of session
+func escapeSessionType(sessionType string) string {
+	return strings.Replace(sessionType, `/`, `\/`, -1)
+}
+
+// unescapeSessionType is the request uri as updated, from the session type in the json string of session
+func unescapeSessionType(sessionType string) string {
+	return strings.Replace(sessionType, `\/`, `/`, -1)
+}
+
 func newSessionMatcher(cnf SessionMatcherCnf) (*sessionMatcher, error) {
 	callOutbounds := map[string]*callOutboundMatcher{}
 	for _, callOutbound := range cnf.CallOutbounds {
@@ -144,12 +191,16 @@ func newSessionMatcher(cnf SessionMatcherCnf) (*sessionMatcher, error) {
 	if err != nil {
 		return nil, err
 	}
+	sampling, err := newSamplingStrategy(cnf.Sampling, cnf.KeepNSessionsPerScene)
+	if err != nil {
+		return nil, err
+	}
 	sessionMatcher := &sessionMatcher{
-		sessionType:           cnf.SessionType,
-		keepNSessionsPerScene: cnf.KeepNSessionsPerScene,
-		callOutbounds:         callOutbounds,
-		inboundRequestPg:      inboundRequestPg,
-		inboundResponsePg:     inboundResponsePg,
+		sessionType:       cnf.SessionType,
+		sampling:          sampling,
+		callOutbounds:     callOutbounds,
+		inboundRequestPg:  inboundRequestPg,
+		inboundResponsePg: inboundResponsePg,
 	}
 	return sessionMatcher, nil
 }
@@ -164,11 +215,17 @@ var NewDiscrminator = func() Discrminator {
 
 type deduplicationState struct {
 	sessionTypes map[string]sessionTypeDS
+	random       *rand.Rand
 }
 
-type sessionTypeDS map[string]int
+type sessionTypeDS map[string]*sceneState
 
 func (ds *deduplicationState) SceneOf(session EventBody) Scene {
+	return ds.SceneOfAt(time.Now(), session)
+}
+
+// SceneOfAt samples the session by the event time, so that the token-bucket is refilled as the events happened
+func (ds *deduplicationState) SceneOfAt(eventTS time.Time, session EventBody) Scene {
 	startTime := time.Now()
 	defer func() {
 		countlog.Trace("event!discr.SceneOf", "latency", time.Since(startTime))
@@ -188,22 +245,60 @@ func (ds *deduplicationState) SceneOf(session EventBody) Scene {
 	}
 	if ds.sessionTypes == nil {
 		ds.sessionTypes = map[string]sessionTypeDS{}
+		ds.random = rand.New(rand.NewSource(startTime.UnixNano()))
 	}
 	perType := ds.sessionTypes[collector.sessionType]
 	if perType == nil {
-		perType = map[string]int{}
+		perType = map[string]*sceneState{}
 		ds.sessionTypes[collector.sessionType] = perType
 	}
 	mapKey := collector.matches.toMapKey()
-	count := perType[mapKey] + 1
-	perType[mapKey] = count
-	if count > collector.sessionMatcher.keepNSessionsPerScene {
-		countlog.Debug("event!filtered_because_exceeded_limit", "sessionType", collector.sessionType)
+	state := perType[mapKey]
+	if state == nil {
+		state = &sceneState{values: collector.matches.ToScene().ToMap()}
+		perType[mapKey] = state
+	}
+	sampling := collector.sessionMatcher.sampling
+	if sampling.strategy == SamplingReservoir {
+		// not written until the end of the window
+		sampling.reserve(state, ReservedSession{EventTS: eventTS, EventBody: session}, ds.random)
+		return nil
+	}
+	if !sampling.keeps(state, eventTS, ds.random) {
+		countlog.Debug("event!filtered_because_not_sampled", "sessionType", collector.sessionType)
 		return nil
 	}
 	return collector.matches.ToScene()
 }
 
+// SceneCounts of the scenes seen since the discriminator created, the session type is unescaped as updated
+func (ds *deduplicationState) SceneCounts() []SceneCount {
+	var counts []SceneCount
+	for sessionType, perType := range ds.sessionTypes {
+		for _, state := range perType {
+			counts = append(counts, SceneCount{
+				SessionType: unescapeSessionType(sessionType),
+				Scene:       state.values,
+				Seen:        state.seen,
+				Kept:        state.kept,
+			})
+		}
+	}
+	return counts
+}
+
+// TakeReserved returns the sessions kept by the reservoirs, the counts of the scenes are not changed
+func (ds *deduplicationState) TakeReserved() []ReservedSession {
+	var reserved []ReservedSession
+	for _, perType := range ds.sessionTypes {
+		for _, state := range perType {
+			reserved = append(reserved, state.reserved...)
+			state.reserved = nil
+		}
+	}
+	return reserved
+}
+
 var sessionTypeStart = []byte(`REQUEST_URI`)
 var sessionTypeEnd = []byte(`\\x`)
 
@@ -263,7 +358,7 @@ func (collector *featureCollector) colCallFromInbound() (sessionMatcher *session
 				return true
 			}
 			if !collector.noTail {
-				notifySessionTailer(sessionType, collector.session)
+				notifySubscribers(sessionType, collector.session)
 			}
 			collector.sessionType = sessionType
 			sessionMatcher = collector.sessionMatcher
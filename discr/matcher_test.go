@@ -115,3 +115,45 @@ func Test_wildcard(t *testing.T) {
 		"user_role": "driver",
 	}, ds.SceneOf([]byte(session)).ToMap())
 }
+
+func Test_list_and_delete(t *testing.T) {
+	should := require.New(t)
+	cnf := SessionMatcherCnf{
+		SessionType:           "/test/list",
+		KeepNSessionsPerScene: 1,
+	}
+	should.Nil(UpdateSessionMatcher(cnf))
+	should.Contains(SessionMatcherCnfs(), cnf)
+	should.NotNil(getSessionMatcher(`\/test\/list`))
+	should.Nil(DeleteSessionMatcher("/test/list"))
+	should.NotContains(SessionMatcherCnfs(), cnf)
+	should.Nil(getSessionMatcher(`\/test\/list`))
+	should.NotNil(DeleteSessionMatcher("/test/list"))
+}
+
+func Test_replace_session_matchers(t *testing.T) {
+	should := require.New(t)
+	should.Nil(ReplaceSessionMatchers([]SessionMatcherCnf{
+		{SessionType: "/test/replace1", KeepNSessionsPerScene: 1},
+	}))
+	err := ReplaceSessionMatchers([]SessionMatcherCnf{
+		{SessionType: "/test/replace2", KeepNSessionsPerScene: 1},
+		{SessionType: "/test/replace3", InboundRequestPatterns: map[string]string{"xxx": "("}},
+		{SessionType: "/test/replace2"},
+	})
+	errs, ok := err.(SessionMatcherCnfErrors)
+	should.True(ok)
+	should.Len(errs, 2)
+	should.Equal(1, errs[0].Index)
+	should.Equal("/test/replace3", errs[0].SessionType)
+	should.Equal(2, errs[1].Index)
+	// the old set is kept
+	should.Equal([]SessionMatcherCnf{
+		{SessionType: "/test/replace1", KeepNSessionsPerScene: 1},
+	}, SessionMatcherCnfs())
+	should.Nil(ReplaceSessionMatchers([]SessionMatcherCnf{
+		{SessionType: "/test/replace2", KeepNSessionsPerScene: 1},
+	}))
+	should.Nil(getSessionMatcher(`\/test\/replace1`))
+	should.NotNil(getSessionMatcher(`\/test\/replace2`))
+}
@@ -5,12 +5,16 @@ import (
 	"regexp"
 	"github.com/v2pro/plz/countlog"
 	"unsafe"
+	"runtime"
 )
 
+// patternGroup is matched by concurrent discriminators, while a hyperscan scratch can only be used by
+// one scan at a time. The scan takes a clone of the scratch from the free list, or clones a new one.
 type patternGroup struct {
 	hdb hyperscan.BlockDatabase
 	exps []*regexp.Regexp
 	scratch *hyperscan.Scratch
+	scratches chan *hyperscan.Scratch
 	keys [][]byte
 }
 
@@ -83,6 +87,7 @@ func newPatternGroup(patterns map[string]string) (*patternGroup, error) {
 		hdb: hdb,
 		exps: exps,
 		scratch: scratch,
+		scratches: make(chan *hyperscan.Scratch, runtime.NumCPU()),
 		keys: keys,
 	}, nil
 }
@@ -91,8 +96,13 @@ func (pg *patternGroup) match(bytes []byte) (patternMatches, error) {
 	if len(bytes) == 0 {
 		return nil, nil
 	}
+	scratch, err := pg.takeScratch()
+	if err != nil {
+		return nil, err
+	}
+	defer pg.returnScratch(scratch)
 	var matches patternMatches
-	err := pg.hdb.Scan(bytes, pg.scratch, func(id uint, from, to uint64, flags uint, context interface{}) error{
+	err = pg.hdb.Scan(bytes, scratch, func(id uint, from, to uint64, flags uint, context interface{}) error{
 		matches = append(matches, patternMatch{
 			match: bytes[from:to],
 			exp: pg.exps[id],
@@ -104,4 +114,24 @@ func (pg *patternGroup) match(bytes []byte) (patternMatches, error) {
 		return nil, err
 	}
 	return matches, nil
-}
\ No newline at end of file
+}
+
+func (pg *patternGroup) takeScratch() (*hyperscan.Scratch, error) {
+	select {
+	case scratch := <-pg.scratches:
+		return scratch, nil
+	default:
+		return pg.scratch.Clone()
+	}
+}
+
+// returnScratch keeps the scratch for next scan, or frees it if enough are kept
+func (pg *patternGroup) returnScratch(scratch *hyperscan.Scratch) {
+	select {
+	case pg.scratches <- scratch:
+	default:
+		if err := scratch.Free(); err != nil {
+			countlog.Error("event!failed to free scratch", "err", err)
+		}
+	}
+}
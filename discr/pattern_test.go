@@ -4,6 +4,7 @@ import (
 	"testing"
 	"github.com/stretchr/testify/require"
 	"regexp"
+	"sync"
 )
 
 func Test_match(t *testing.T) {
@@ -22,6 +23,32 @@ func Test_match(t *testing.T) {
 	}, scene.ToMap())
 }
 
+func Test_match_concurrently(t *testing.T) {
+	should := require.New(t)
+	pg, err := newPatternGroup(map[string]string{
+		"product_id": `product_id=(\d+)`})
+	should.Nil(err)
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if _, err := pg.match([]byte(`product_id=1`)); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		should.Nil(err)
+	}
+}
+
 func Benchmark_small_string(b *testing.B) {
 	p, err := regexp.Compile(`product_id=(\d+)`)
 	if err != nil {
@@ -0,0 +1,147 @@
+package discr
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+)
+
+const (
+	// SamplingFirstN keeps the first KeepNSessionsPerScene sessions of each scene in the window
+	SamplingFirstN = "first-n"
+	// SamplingReservoir keeps a uniform sample of KeepNSessionsPerScene sessions of each scene over the window.
+	// The sample is only known at the end of the window, so the kept sessions are held by the discriminator
+	// and written by the store at rotation or Close, they are lost if the process crashes before.
+	SamplingReservoir = "reservoir"
+	// SamplingTokenBucket keeps at most PerMinute sessions of each scene per minute of the event time,
+	// with the burst of PerMinute
+	SamplingTokenBucket = "token-bucket"
+	// SamplingProbability keeps each session with the Probability, regardless of the scene
+	SamplingProbability = "probability"
+)
+
+// SamplingCnf selects which sessions of a scene are kept, first-n by default
+type SamplingCnf struct {
+	Strategy    string
+	Probability float64
+	PerMinute   int
+}
+
+type samplingStrategy struct {
+	strategy    string
+	keepN       int
+	probability float64
+	perMinute   float64
+}
+
+func newSamplingStrategy(cnf SamplingCnf, keepN int) (*samplingStrategy, error) {
+	strategy := &samplingStrategy{
+		strategy:    cnf.Strategy,
+		keepN:       keepN,
+		probability: cnf.Probability,
+		perMinute:   float64(cnf.PerMinute),
+	}
+	switch cnf.Strategy {
+	case "":
+		strategy.strategy = SamplingFirstN
+	case SamplingFirstN, SamplingReservoir:
+	case SamplingTokenBucket:
+		if cnf.PerMinute <= 0 {
+			return nil, errors.New("token-bucket sampling requires positive PerMinute")
+		}
+	case SamplingProbability:
+		if cnf.Probability <= 0 || cnf.Probability > 1 {
+			return nil, errors.New("probability sampling requires Probability in (0, 1]")
+		}
+	default:
+		return nil, errors.New("unknown sampling strategy: " + cnf.Strategy)
+	}
+	return strategy, nil
+}
+
+// sceneState counts the occurrences of a scene within the window of the discriminator
+type sceneState struct {
+	values map[string]string
+	seen   int
+	kept   int
+	// tokens are refilled since lastRefill, only for token-bucket
+	tokens     float64
+	lastRefill time.Time
+	// reserved are the sessions kept by reservoir, waiting for the end of the window
+	reserved []ReservedSession
+}
+
+// keeps counts the occurrence at the event time, and tells if the session should be stored
+func (strategy *samplingStrategy) keeps(state *sceneState, now time.Time, random *rand.Rand) bool {
+	state.seen++
+	var kept bool
+	switch strategy.strategy {
+	case SamplingTokenBucket:
+		if state.lastRefill.IsZero() {
+			state.tokens = strategy.perMinute
+			state.lastRefill = now
+		} else if now.After(state.lastRefill) {
+			// the late events spend the tokens without refilling
+			state.tokens += now.Sub(state.lastRefill).Minutes() * strategy.perMinute
+			if state.tokens > strategy.perMinute {
+				state.tokens = strategy.perMinute
+			}
+			state.lastRefill = now
+		}
+		kept = state.tokens >= 1
+		if kept {
+			state.tokens--
+		}
+	case SamplingProbability:
+		kept = random.Float64() < strategy.probability
+	default:
+		kept = state.seen <= strategy.keepN
+	}
+	if kept {
+		state.kept++
+	}
+	return kept
+}
+
+// reserve counts the occurrence, and puts the session into the reservoir of the scene in place of a random one
+// with probability keepN/seen, so that every session of the window is kept with equal probability
+func (strategy *samplingStrategy) reserve(state *sceneState, session ReservedSession, random *rand.Rand) {
+	state.seen++
+	if len(state.reserved) < strategy.keepN {
+		state.reserved = append(state.reserved, session)
+	} else if i := random.Intn(state.seen); i < strategy.keepN {
+		state.reserved[i] = session
+	}
+	state.kept = len(state.reserved)
+}
+
+// SceneCount is the occurrences of a scene seen by the discriminator, and how many of them are kept
+type SceneCount struct {
+	SessionType string
+	Scene       map[string]string
+	Seen        int
+	Kept        int
+}
+
+// TimedDiscrminator is implemented by the discriminator sampling by the event time,
+// SceneOf samples by the current time instead
+type TimedDiscrminator interface {
+	SceneOfAt(eventTS time.Time, eventBody EventBody) Scene
+}
+
+// SceneCounter is implemented by the discriminator keeping the counts of the scenes it has seen
+type SceneCounter interface {
+	SceneCounts() []SceneCount
+}
+
+// ReservedSession is a session kept by the reservoir sampling, to be written at the end of the window
+type ReservedSession struct {
+	EventTS   time.Time
+	EventBody EventBody
+}
+
+// Reservoir is implemented by the discriminator holding the sessions of reservoir sampling
+type Reservoir interface {
+	// TakeReserved returns the sessions kept by the reservoirs of all scenes, and empties the reservoirs
+	TakeReserved() []ReservedSession
+}
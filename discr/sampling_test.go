@@ -0,0 +1,86 @@
+package discr
+
+import (
+	"testing"
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"time"
+)
+
+func Test_sampling_first_n(t *testing.T) {
+	should := require.New(t)
+	strategy, err := newSamplingStrategy(SamplingCnf{}, 2)
+	should.Nil(err)
+	state := &sceneState{}
+	random := rand.New(rand.NewSource(1))
+	should.True(strategy.keeps(state, time.Now(), random))
+	should.True(strategy.keeps(state, time.Now(), random))
+	should.False(strategy.keeps(state, time.Now(), random))
+	should.Equal(3, state.seen)
+	should.Equal(2, state.kept)
+}
+
+func Test_sampling_reservoir(t *testing.T) {
+	should := require.New(t)
+	strategy, err := newSamplingStrategy(SamplingCnf{Strategy: SamplingReservoir}, 10)
+	should.Nil(err)
+	state := &sceneState{}
+	random := rand.New(rand.NewSource(1))
+	start := time.Now()
+	for i := 0; i < 10000; i++ {
+		strategy.reserve(state, ReservedSession{EventTS: start.Add(time.Duration(i) * time.Second)}, random)
+	}
+	should.Equal(10000, state.seen)
+	// bounded by keepN
+	should.Equal(10, state.kept)
+	should.Len(state.reserved, 10)
+	lateKept := 0
+	for _, session := range state.reserved {
+		if session.EventTS.Sub(start) >= 5000*time.Second {
+			lateKept++
+		}
+	}
+	should.True(lateKept > 0)
+	ds := &deduplicationState{sessionTypes: map[string]sessionTypeDS{"/hello": {"": state}}}
+	should.Len(ds.TakeReserved(), 10)
+	should.Len(ds.TakeReserved(), 0)
+	// the counts are kept for the scene stats
+	should.Equal([]SceneCount{{SessionType: "/hello", Seen: 10000, Kept: 10}}, ds.SceneCounts())
+}
+
+func Test_sampling_token_bucket(t *testing.T) {
+	should := require.New(t)
+	strategy, err := newSamplingStrategy(SamplingCnf{Strategy: SamplingTokenBucket, PerMinute: 2}, 0)
+	should.Nil(err)
+	state := &sceneState{}
+	now := time.Now()
+	should.True(strategy.keeps(state, now, nil))
+	should.True(strategy.keeps(state, now, nil))
+	should.False(strategy.keeps(state, now.Add(time.Second), nil))
+	should.True(strategy.keeps(state, now.Add(31*time.Second), nil))
+	// the late event does not refill
+	should.False(strategy.keeps(state, now.Add(time.Second), nil))
+	should.Equal(5, state.seen)
+	should.Equal(3, state.kept)
+}
+
+func Test_sampling_probability(t *testing.T) {
+	should := require.New(t)
+	_, err := newSamplingStrategy(SamplingCnf{Strategy: SamplingProbability}, 0)
+	should.NotNil(err)
+	strategy, err := newSamplingStrategy(SamplingCnf{Strategy: SamplingProbability, Probability: 0.1}, 0)
+	should.Nil(err)
+	state := &sceneState{}
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		strategy.keeps(state, time.Now(), random)
+	}
+	should.Equal(10000, state.seen)
+	should.True(state.kept > 800 && state.kept < 1200)
+}
+
+func Test_sampling_unknown(t *testing.T) {
+	should := require.New(t)
+	_, err := newSamplingStrategy(SamplingCnf{Strategy: "last-n"}, 0)
+	should.NotNil(err)
+}
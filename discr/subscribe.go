@@ -0,0 +1,192 @@
+package discr
+
+import (
+	"sync"
+	"sync/atomic"
+	"errors"
+)
+
+// defaultSubscribeBufferSize is used when SubscribeFilter.BufferSize is 0
+const defaultSubscribeBufferSize = 1024
+
+// SubscribeFilter selects the sessions delivered to the subscription
+type SubscribeFilter struct {
+	// SessionType as in the request uri, "*" or empty for all
+	SessionType string
+	// Matcher is tried on each delivered session, instead of the updated matcher of the session type
+	Matcher SessionMatcherCnf
+	// Where keeps only the sessions passing the filter, the others are skipped without being delivered
+	Where SceneFilterCnf
+	// BufferSize bounds the sessions waiting to be received, the sessions exceeding it are dropped.
+	// It is defaultSubscribeBufferSize if 0, and must not be negative
+	BufferSize int
+}
+
+// Subscription receives the sessions as they are collected by SceneOf, until Cancel
+type Subscription struct {
+	// accessed atomically, kept first for 64-bit alignment
+	dropped     uint64
+	sessionType string
+	// escapedSessionType is the session type as it appears in the json string of session
+	escapedSessionType string
+	matcher            *sessionMatcher
+	filter             *sceneFilter
+	buffer             chan tailedSession
+	sessions           chan TailedSession
+	stopping           chan struct{}
+	cancelOnce         sync.Once
+}
+
+var subscriptions = map[*Subscription]struct{}{}
+var subscriptionsMutex = &sync.RWMutex{}
+
+// Subscribe starts delivering the sessions selected by the filter. The collecting never waits for the subscriber,
+// the session is dropped and counted if the buffer is full.
+func Subscribe(filter SubscribeFilter) (*Subscription, error) {
+	matcher, err := newSessionMatcher(filter.Matcher)
+	if err != nil {
+		return nil, err
+	}
+	sceneFilter, err := newSceneFilter(filter.Where)
+	if err != nil {
+		return nil, err
+	}
+	if filter.SessionType == "" {
+		filter.SessionType = "*"
+	}
+	if filter.BufferSize < 0 {
+		return nil, errors.New("subscribe buffer size is negative")
+	}
+	if filter.BufferSize == 0 {
+		filter.BufferSize = defaultSubscribeBufferSize
+	}
+	subscription := &Subscription{
+		sessionType:        filter.SessionType,
+		escapedSessionType: escapeSessionType(filter.SessionType),
+		matcher:            matcher,
+		filter:             sceneFilter,
+		buffer:             make(chan tailedSession, filter.BufferSize),
+		sessions:           make(chan TailedSession),
+		stopping:           make(chan struct{}),
+	}
+	subscriptionsMutex.Lock()
+	subscriptions[subscription] = struct{}{}
+	subscriptionsMutex.Unlock()
+	go subscription.deliver()
+	return subscription, nil
+}
+
+// Sessions is closed after Cancel
+func (subscription *Subscription) Sessions() <-chan TailedSession {
+	return subscription.sessions
+}
+
+// Dropped counts the sessions lost as the subscriber did not keep up
+func (subscription *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&subscription.dropped)
+}
+
+// Cancel stops the delivery, it can be called more than once
+func (subscription *Subscription) Cancel() {
+	subscription.cancelOnce.Do(func() {
+		subscriptionsMutex.Lock()
+		delete(subscriptions, subscription)
+		subscriptionsMutex.Unlock()
+		close(subscription.stopping)
+	})
+}
+
+// deliver tries the matcher and the filter out of the collecting goroutine, then waits for the subscriber
+func (subscription *Subscription) deliver() {
+	defer close(subscription.sessions)
+	for {
+		select {
+		case <-subscription.stopping:
+			return
+		case tailed := <-subscription.buffer:
+			matches, matchErr := tryMatcher(tailed.session, subscription.matcher)
+			delivered := TailedSession{
+				SessionType: tailed.sessionType,
+				Session:     tailed.session,
+				Scene:       matches.ToScene(),
+				MatchErr:    matchErr,
+			}
+			if !subscription.filter.accepts(delivered) {
+				continue
+			}
+			select {
+			case <-subscription.stopping:
+				return
+			case subscription.sessions <- delivered:
+			}
+		}
+	}
+}
+
+func (subscription *Subscription) accepts(sessionType string) bool {
+	return subscription.sessionType == "*" || subscription.sessionType == sessionType ||
+		subscription.escapedSessionType == sessionType
+}
+
+// notifySubscribers is called by SceneOf, it must not block
+func notifySubscribers(sessionType string, session []byte) {
+	subscriptionsMutex.RLock()
+	defer subscriptionsMutex.RUnlock()
+	var copied []byte
+	for subscription := range subscriptions {
+		if !subscription.accepts(sessionType) {
+			continue
+		}
+		if copied == nil {
+			// the caller may reuse the session after SceneOf returns
+			copied = append([]byte(nil), session...)
+		}
+		select {
+		case subscription.buffer <- tailedSession{sessionType: sessionType, session: copied}:
+		default:
+			atomic.AddUint64(&subscription.dropped, 1)
+		}
+	}
+}
+
+// SessionTailer is called with the session type and the session
+type SessionTailer func(string, []byte)
+
+// maxPendingSessionTailers bounds the tailers waiting for the sessions of one session type
+const maxPendingSessionTailers = 1024
+
+var pendingSessionTailers = map[string]int{}
+var pendingSessionTailersMutex = &sync.Mutex{}
+
+// AddSessionTailer calls the tailer once with the next session of the session type, "*" for any.
+// It is kept for the existing callers, Subscribe delivers every session until cancelled.
+func AddSessionTailer(sessionType string, sessionTailer SessionTailer) error {
+	pendingSessionTailersMutex.Lock()
+	if pendingSessionTailers[sessionType] >= maxPendingSessionTailers {
+		pendingSessionTailersMutex.Unlock()
+		return errors.New("overflow")
+	}
+	pendingSessionTailers[sessionType]++
+	pendingSessionTailersMutex.Unlock()
+	subscription, err := Subscribe(SubscribeFilter{SessionType: sessionType, BufferSize: 1})
+	if err != nil {
+		donePendingSessionTailer(sessionType)
+		return err
+	}
+	go func() {
+		defer donePendingSessionTailer(sessionType)
+		tailed := <-subscription.Sessions()
+		subscription.Cancel()
+		sessionTailer(tailed.SessionType, tailed.Session)
+	}()
+	return nil
+}
+
+func donePendingSessionTailer(sessionType string) {
+	pendingSessionTailersMutex.Lock()
+	defer pendingSessionTailersMutex.Unlock()
+	pendingSessionTailers[sessionType]--
+	if pendingSessionTailers[sessionType] == 0 {
+		delete(pendingSessionTailers, sessionType)
+	}
+}
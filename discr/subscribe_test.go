@@ -0,0 +1,103 @@
+package discr
+
+import (
+	"testing"
+	"github.com/stretchr/testify/require"
+	"time"
+)
+
+func Test_subscribe(t *testing.T) {
+	should := require.New(t)
+	subscription, err := Subscribe(SubscribeFilter{SessionType: "/sub", Matcher: SessionMatcherCnf{
+		InboundResponsePatterns: map[string]string{"product_id": `product_id=(\d+)`},
+	}})
+	should.Nil(err)
+	defer subscription.Cancel()
+	notifySubscribers("/other", []byte(`{}`))
+	notifySubscribers("/sub", []byte(`{"ReturnInbound":{"Response":"product_id=3"}}`))
+	select {
+	case tailed := <-subscription.Sessions():
+		should.Equal("/sub", tailed.SessionType)
+		should.Nil(tailed.MatchErr)
+		should.Equal(map[string]string{"product_id": "3"}, tailed.Scene.ToMap())
+	case <-time.After(time.Second):
+		t.Fatal("session not delivered")
+	}
+	should.Equal(uint64(0), subscription.Dropped())
+}
+
+func Test_subscribe_drop(t *testing.T) {
+	should := require.New(t)
+	subscription, err := Subscribe(SubscribeFilter{BufferSize: 1})
+	should.Nil(err)
+	for i := 0; i < 3; i++ {
+		notifySubscribers("/drop", []byte(`{}`))
+	}
+	// one is buffered, the other may be taken by the delivering goroutine
+	dropped := subscription.Dropped()
+	should.True(dropped >= 1 && dropped <= 2)
+	subscription.Cancel()
+	subscription.Cancel()
+	for range subscription.Sessions() {
+	}
+	notifySubscribers("/drop", []byte(`{}`))
+	should.Equal(dropped, subscription.Dropped())
+}
+
+func Test_subscribe_where(t *testing.T) {
+	should := require.New(t)
+	subscription, err := Subscribe(SubscribeFilter{
+		SessionType: "/where",
+		Matcher: SessionMatcherCnf{
+			InboundResponsePatterns: map[string]string{"product_id": `product_id=(\d+)`},
+		},
+		Where: SceneFilterCnf{
+			SceneEquals:     map[string]string{"product_id": "3"},
+			SessionPatterns: map[string]string{"vip": `vip`},
+		},
+	})
+	should.Nil(err)
+	defer subscription.Cancel()
+	notifySubscribers("/where", []byte(`{"ReturnInbound":{"Response":"product_id=4&vip"}}`))
+	notifySubscribers("/where", []byte(`{"ReturnInbound":{"Response":"product_id=3"}}`))
+	notifySubscribers("/where", []byte(`{"ReturnInbound":{"Response":"product_id=3&vip"}}`))
+	select {
+	case tailed := <-subscription.Sessions():
+		should.Equal(`{"ReturnInbound":{"Response":"product_id=3&vip"}}`, string(tailed.Session))
+	case <-time.After(time.Second):
+		t.Fatal("session not delivered")
+	}
+}
+
+func Test_subscribe_buffer_size(t *testing.T) {
+	should := require.New(t)
+	_, err := Subscribe(SubscribeFilter{BufferSize: -1})
+	should.NotNil(err)
+	subscription, err := Subscribe(SubscribeFilter{})
+	should.Nil(err)
+	defer subscription.Cancel()
+	should.Equal(defaultSubscribeBufferSize, cap(subscription.buffer))
+}
+
+func Test_add_session_tailer(t *testing.T) {
+	should := require.New(t)
+	tailed := make(chan string, 2)
+	should.Nil(AddSessionTailer("/tailer", func(sessionType string, session []byte) {
+		tailed <- sessionType + " " + string(session)
+	}))
+	notifySubscribers("/other", []byte(`{}`))
+	notifySubscribers("/tailer", []byte(`{"a":1}`))
+	select {
+	case got := <-tailed:
+		should.Equal(`/tailer {"a":1}`, got)
+	case <-time.After(time.Second):
+		t.Fatal("session not tailed")
+	}
+	// called only once
+	notifySubscribers("/tailer", []byte(`{"a":2}`))
+	select {
+	case got := <-tailed:
+		t.Fatal("tailed again: " + got)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -5,97 +5,125 @@ import (
 	"time"
 	"github.com/v2pro/plz/countlog"
 	"github.com/json-iterator/go"
+	"context"
+	"errors"
+	"html"
 )
 
+// tailIdleTimeout stops the tail if no session comes within the time
+const tailIdleTimeout = time.Minute
+
+var ErrTailTimeout = errors.New("timeout")
+var ErrTailLimitReached = errors.New("limit reached")
+
+// TailedSession is passed to the callback of TailSessions
+type TailedSession struct {
+	SessionType string
+	Session     []byte
+	// Scene is matched by the matcher under trial, nil if not matched
+	Scene Scene
+	// MatchErr tells why the session can not be matched
+	MatchErr error
+}
+
 type tailedSession struct {
 	sessionType string
 	session     []byte
 }
 
-func Tail(respWriter http.ResponseWriter, sessionType string, showSession bool, limit int, cnf SessionMatcherCnf) {
-	if len(sessionType) == 0 {
-		sessionType = "*"
-	}
-	sessionMatcher, err := newSessionMatcher(cnf)
+// TailSessions calls onSession with the sessions subscribed by the filter as they are collected,
+// each is tried by the matcher of the filter. It returns the error of onSession, the context or why the tail ends,
+// such as ErrTailLimitReached after more than limit sessions (limit 0 is unlimited).
+func TailSessions(ctx context.Context, filter SubscribeFilter, limit int, onSession func(TailedSession) error) error {
+	subscription, err := Subscribe(filter)
 	if err != nil {
-		countlog.Error("event!tail.err", "err", err)
-		return
-	}
-	sessionChannel := make(chan tailedSession)
-	tailer := func(sessionType string, session []byte) {
-		sessionChannel <- tailedSession{sessionType: sessionType, session: session}
+		return err
 	}
+	defer func() {
+		subscription.Cancel()
+		if dropped := subscription.Dropped(); dropped > 0 {
+			countlog.Warn("event!tail.dropped", "sessionType", filter.SessionType, "dropped", dropped)
+		}
+	}()
 	count := 0
 	for {
-		err := AddSessionTailer(sessionType, tailer)
-		if err != nil {
-			respWriter.Write([]byte("overflow!!!\n"))
-			return
-		}
-		timer := time.NewTimer(time.Minute)
+		timer := time.NewTimer(tailIdleTimeout)
 		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
 		case <-timer.C:
-			respWriter.Write([]byte("timeout!!!\n"))
-			return
-		case tailedSession := <-sessionChannel:
-			if _, err = respWriter.Write([]byte(`<span style="color:red;">`)); err != nil {
-				countlog.Error("event!tail.err", "err", err)
-				return
-			}
-			if _, err = respWriter.Write([]byte(tailedSession.sessionType)); err != nil {
-				countlog.Error("event!tail.err", "err", err)
-				return
-			}
-			if _, err = respWriter.Write([]byte("</span><br/>\n")); err != nil {
-				countlog.Error("event!tail.err", "err", err)
-				return
-			}
-			matches, err := tryMatcher(tailedSession.session, sessionMatcher)
+			return ErrTailTimeout
+		case tailed := <-subscription.Sessions():
+			timer.Stop()
+			err = onSession(tailed)
 			if err != nil {
-				if _, err = respWriter.Write([]byte(err.Error() + "<br/>")); err != nil {
-					countlog.Error("event!tail.err", "err", err)
-					return
-				}
-			} else if matches != nil {
-				if _, err = respWriter.Write([]byte(`<span style="color:blue;">`)); err != nil {
-					countlog.Error("event!tail.err", "err", err)
-					return
-				}
-				for k, v := range matches.ToScene().ToMap() {
-					if _, err = respWriter.Write([]byte(k + " => " + v + "<br/>")); err != nil {
-						countlog.Error("event!tail.err", "err", err)
-						return
-					}
-				}
-				if _, err = respWriter.Write([]byte("</span><br/>\n")); err != nil {
-					countlog.Error("event!tail.err", "err", err)
-					return
-				}
-			}
-			if showSession {
-				if _, err = respWriter.Write([]byte("<pre>\n")); err != nil {
-					countlog.Error("event!tail.err", "err", err)
-					return
-				}
-				if _, err = respWriter.Write(tailedSession.session); err != nil {
-					countlog.Error("event!tail.err", "err", err)
-					return
-				}
-				if _, err = respWriter.Write([]byte("</pre><br/>\n")); err != nil {
-					countlog.Error("event!tail.err", "err", err)
-					return
-				}
-			}
-			if f, ok := respWriter.(http.Flusher); ok {
-				f.Flush()
+				return err
 			}
 			count++
 			if limit > 0 && count > limit {
-				respWriter.Write([]byte("limit reached!!!\n"))
-				return
+				return ErrTailLimitReached
+			}
+		}
+	}
+}
+
+func Tail(respWriter http.ResponseWriter, sessionType string, showSession bool, limit int, cnf SessionMatcherCnf,
+	filterCnf SceneFilterCnf) {
+	filter := SubscribeFilter{SessionType: sessionType, Matcher: cnf, Where: filterCnf}
+	err := TailSessions(context.Background(), filter, limit, func(tailed TailedSession) error {
+		return writeTailedSession(respWriter, tailed, showSession)
+	})
+	switch err {
+	case ErrTailTimeout, ErrTailLimitReached:
+		respWriter.Write([]byte(err.Error() + "!!!\n"))
+	default:
+		countlog.Error("event!tail.err", "err", err)
+	}
+}
+
+func writeTailedSession(respWriter http.ResponseWriter, tailed TailedSession, showSession bool) error {
+	if _, err := respWriter.Write([]byte(`<span style="color:red;">`)); err != nil {
+		return err
+	}
+	if _, err := respWriter.Write([]byte(html.EscapeString(tailed.SessionType))); err != nil {
+		return err
+	}
+	if _, err := respWriter.Write([]byte("</span><br/>\n")); err != nil {
+		return err
+	}
+	if tailed.MatchErr != nil {
+		if _, err := respWriter.Write([]byte(html.EscapeString(tailed.MatchErr.Error()) + "<br/>")); err != nil {
+			return err
+		}
+	} else if tailed.Scene != nil {
+		if _, err := respWriter.Write([]byte(`<span style="color:blue;">`)); err != nil {
+			return err
+		}
+		for k, v := range tailed.Scene.ToMap() {
+			if _, err := respWriter.Write([]byte(html.EscapeString(k+" => "+v) + "<br/>")); err != nil {
+				return err
 			}
 		}
+		if _, err := respWriter.Write([]byte("</span><br/>\n")); err != nil {
+			return err
+		}
+	}
+	if showSession {
+		if _, err := respWriter.Write([]byte("<pre>\n")); err != nil {
+			return err
+		}
+		if _, err := respWriter.Write([]byte(html.EscapeString(string(tailed.Session)))); err != nil {
+			return err
+		}
+		if _, err := respWriter.Write([]byte("</pre><br/>\n")); err != nil {
+			return err
+		}
+	}
+	if f, ok := respWriter.(http.Flusher); ok {
+		f.Flush()
 	}
+	return nil
 }
 
 func tryMatcher(session []byte, matcher *sessionMatcher) (patternMatches, error) {
@@ -0,0 +1,50 @@
+package evtstore
+
+import (
+	"encoding/binary"
+	"errors"
+	"bytes"
+)
+
+const cursorSize = blockIdSize + 4
+
+// Cursor points at an entry within a block, the pagination continues from there
+type Cursor []byte // EventBlockId|entryIndex(4byte)
+
+func NewCursor(blockId EventBlockId, entryIndex uint32) Cursor {
+	cursor := make(Cursor, cursorSize)
+	copy(cursor, blockId)
+	binary.LittleEndian.PutUint32(cursor[blockIdSize:], entryIndex)
+	return cursor
+}
+
+// ParseCursor validates the cursor given back by client
+func ParseCursor(buf []byte) (Cursor, error) {
+	if len(buf) != cursorSize {
+		return nil, errors.New("invalid cursor size")
+	}
+	cursor := Cursor(buf)
+	fileName := cursor.BlockId().FileName()
+	if !isDataFileName(fileName) {
+		return nil, errors.New("invalid file name in cursor: " + fileName)
+	}
+	return cursor, nil
+}
+
+func (cursor Cursor) BlockId() EventBlockId {
+	return EventBlockId(cursor[:blockIdSize])
+}
+
+// EntryIndex is the count of entries already returned from the block
+func (cursor Cursor) EntryIndex() uint32 {
+	return binary.LittleEndian.Uint32(cursor[blockIdSize:])
+}
+
+func (cursor Cursor) at(blockId EventBlockId) bool {
+	return cursor != nil && bytes.Equal(cursor.BlockId(), blockId)
+}
+
+// consumed tells if all the entries of the block have been returned before the cursor
+func (cursor Cursor) consumed(blockId EventBlockId, entry blockIndexEntry) bool {
+	return cursor.at(blockId) && cursor.EntryIndex() >= uint32(entry.EntriesCount())
+}
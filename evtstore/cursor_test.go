@@ -0,0 +1,96 @@
+package evtstore
+
+import (
+	"fmt"
+	"testing"
+	"github.com/stretchr/testify/require"
+	"time"
+)
+
+func Test_list_after_cursor(t *testing.T) {
+	reset()
+	should := require.New(t)
+	var testStore = newTestStore()
+	should.Nil(testStore.Add([]byte(`{"url":"/hello1"}`)))
+	testStore.flushInputQueue()
+	should.Nil(testStore.Add([]byte(`{"url":"/hello2"}`)))
+	testStore.flushInputQueue()
+	events, cursor, err := testStore.ListAfter(nil, epoch, epoch.Add(time.Hour), 0, 0)
+	should.Nil(err)
+	blockId, _, events := events.Next()
+	should.Equal(uint64(0x1E), blockId.Offset())
+	should.Len(events, 0)
+	should.Equal(blockId, cursor.BlockId())
+	should.Equal(uint32(1), cursor.EntryIndex())
+	events, cursor, err = testStore.ListAfter(cursor, epoch, epoch.Add(time.Hour), 0, 0)
+	should.Nil(err)
+	blockId, block, events := events.Next()
+	should.Equal(uint64(0x4F), blockId.Offset())
+	entries, err := block.Decompress()
+	should.Nil(err)
+	entry, _ := entries.Next()
+	should.Equal(`{"url":"/hello2"}`, string(entry.EventBody()))
+	should.Len(events, 0)
+	events, nextCursor, err := testStore.ListAfter(cursor, epoch, epoch.Add(time.Hour), 0, 0)
+	should.Nil(err)
+	should.Len(events, 0)
+	should.Equal(cursor, nextCursor)
+}
+
+func Test_query_after_cursor(t *testing.T) {
+	reset()
+	should := require.New(t)
+	var testStore = newTestStore()
+	should.Nil(testStore.Add([]byte(`{"url":"/hello1"}`)))
+	should.Nil(testStore.Add([]byte(`{"url":"/hello2"}`)))
+	should.Nil(testStore.Add([]byte(`{"url":"/hello3"}`)))
+	testStore.flushInputQueue()
+	iter, cursor, err := testStore.QueryAfter(nil, epoch, epoch.Add(time.Hour), 0, 2)
+	should.Nil(err)
+	should.Equal(`{"url":"/hello1"}`, string(iter.Next().Body))
+	should.Equal(`{"url":"/hello2"}`, string(iter.Next().Body))
+	should.Equal(uint32(2), cursor.EntryIndex())
+	// events added after the first page do not shift the next page
+	should.Nil(testStore.Add([]byte(`{"url":"/hello4"}`)))
+	testStore.flushInputQueue()
+	iter, cursor, err = testStore.QueryAfter(cursor, epoch, epoch.Add(time.Hour), 0, 2)
+	should.Nil(err)
+	should.Equal(`{"url":"/hello3"}`, string(iter.Next().Body))
+	should.Equal(`{"url":"/hello4"}`, string(iter.Next().Body))
+	should.False(iter.HasNext())
+	should.Equal(uint32(1), cursor.EntryIndex())
+}
+
+func Test_query_after_cursor_with_skip(t *testing.T) {
+	reset()
+	should := require.New(t)
+	var testStore = newTestStore()
+	for i := 1; i <= 4; i++ {
+		should.Nil(testStore.Add([]byte(fmt.Sprintf(`{"url":"/hello%d"}`, i))))
+	}
+	testStore.flushInputQueue()
+	for i := 5; i <= 7; i++ {
+		should.Nil(testStore.Add([]byte(fmt.Sprintf(`{"url":"/hello%d"}`, i))))
+	}
+	testStore.flushInputQueue()
+	_, cursor, err := testStore.QueryAfter(nil, epoch, epoch.Add(time.Hour), 0, 2)
+	should.Nil(err)
+	should.Equal(uint32(2), cursor.EntryIndex())
+	// the block of the cursor only has 2 entries left to skip
+	iter, _, err := testStore.QueryAfter(cursor, epoch, epoch.Add(time.Hour), 4, 2)
+	should.Nil(err)
+	should.Equal(`{"url":"/hello7"}`, string(iter.Next().Body))
+	should.False(iter.HasNext())
+}
+
+func Test_parse_cursor(t *testing.T) {
+	should := require.New(t)
+	_, err := ParseCursor([]byte{1, 2, 3})
+	should.NotNil(err)
+	_, err = ParseCursor(NewCursor(NewEventBlockId("../../etc/ab", 0x1E), 0))
+	should.NotNil(err)
+	cursor, err := ParseCursor(NewCursor(NewEventBlockId("201701010000", 0x1E), 3))
+	should.Nil(err)
+	should.Equal("201701010000", cursor.BlockId().FileName())
+	should.Equal(uint32(3), cursor.EntryIndex())
+}
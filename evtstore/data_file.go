@@ -0,0 +1,174 @@
+package evtstore
+
+import (
+	"errors"
+	"os"
+	"path"
+	"io"
+	"time"
+	"github.com/blang/vfs"
+	"github.com/v2pro/quoll/timeutil"
+	"github.com/v2pro/plz/countlog"
+)
+
+// dataFile is a store file opened for appending blocks, along with its index.
+// The current window keeps one open, the late events open the file of their window briefly.
+type dataFile struct {
+	file      vfs.File
+	header    fileHeader
+	offset    int64 // where next block starts
+	index     blockIndex
+	indexFile vfs.File
+}
+
+// openDataFile opens the file starting from fileTime for appending,
+// the existing file is written in its own format version
+func (store *Store) openDataFile(fileTime time.Time) (*dataFile, error) {
+	fileName := store.fileNameOf(fileTime)
+	target := &dataFile{
+		header: newFileHeader(formatVersion, fileTime, timeutil.ShiftFor(store.Config.RotationInterval)),
+	}
+	filePath := path.Join(store.RootDir, fileName)
+	if _, err := store.fs.Stat(filePath); err == nil {
+		header, index, err := store.loadExistingFile(fileName)
+		if err != nil {
+			return nil, err
+		}
+		if header != nil {
+			target.header = header
+		}
+		target.index = index
+	}
+	file, err := store.fs.OpenFile(filePath, os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		file, err = store.fs.OpenFile(filePath, os.O_WRONLY|os.O_APPEND, 0666)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		_, err = file.Write(target.header)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		store.repairedFiles[fileName] = true
+	}
+	indexFile, err := store.fs.OpenFile(
+		path.Join(store.RootDir, indexFileName(fileName)), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	target.offset, err = file.Seek(0, io.SeekEnd)
+	if err != nil {
+		file.Close()
+		indexFile.Close()
+		return nil, err
+	}
+	target.file = file
+	target.indexFile = indexFile
+	return target, nil
+}
+
+// loadExistingFile reads the header and index of the file to append. The file is repaired the first time
+// opened since started, as the process might die in the middle of writing it, later its index is loaded.
+// The nil header tells the file is removed as torn.
+func (store *Store) loadExistingFile(fileName string) (fileHeader, blockIndex, error) {
+	if !store.repairedFiles[fileName] {
+		header, index, err := store.repairFile(fileName)
+		if err != nil {
+			return nil, nil, err
+		}
+		store.repairedFiles[fileName] = true
+		return header, index, nil
+	}
+	file, err := store.fs.OpenFile(path.Join(store.RootDir, fileName), os.O_RDONLY, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	header, err := readFileHeader(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	index, rebuilt, err := store.loadIndex(fileName, file, header)
+	if err != nil {
+		return nil, nil, err
+	}
+	if rebuilt {
+		// the index file is appended to, so it has to be rewritten along with the repaired data file
+		delete(store.repairedFiles, fileName)
+		return store.loadExistingFile(fileName)
+	}
+	return header, index, nil
+}
+
+// errUnstorableTime is for the timestamp no file can hold, such as before 1970
+var errUnstorableTime = errors.New("event timestamp can not be stored")
+
+// canStore tells if the new file of the window can hold the timestamp
+func (store *Store) canStore(ts time.Time) bool {
+	shift := timeutil.ShiftFor(store.Config.RotationInterval)
+	return newFileHeader(formatVersion, store.windowStart(ts), shift).canCompress(ts)
+}
+
+// openDataFileFor opens the file of the window to append the event at ts. If the existing file of the window
+// can not hold the timestamp, such as an hourly file after the interval is changed to daily,
+// the window continues in a new file starting from the minute of the event.
+func (store *Store) openDataFileFor(ts time.Time) (*dataFile, error) {
+	if !store.canStore(ts) {
+		return nil, errUnstorableTime
+	}
+	target, err := store.openDataFile(store.windowStart(ts))
+	if err != nil {
+		return nil, err
+	}
+	if target.header.canCompress(ts) {
+		return target, nil
+	}
+	if err := target.close(); err != nil {
+		return nil, err
+	}
+	fileTime := ts.Truncate(time.Minute)
+	countlog.Info("event!store.continue_window_in_new_file", "ts", ts,
+		"ctsShift", target.header.CTSShift(), "fileName", store.fileNameOf(fileTime))
+	target, err = store.openDataFile(fileTime)
+	if err != nil {
+		return nil, err
+	}
+	// the existing file of the minute might be written with a shorter rotation interval as well
+	if !target.header.canCompress(ts) {
+		target.close()
+		return nil, errUnstorableTime
+	}
+	return target, nil
+}
+
+// appendBlock writes the block header (with checksum) and compressed body, then indexes the block
+func (target *dataFile) appendBlock(blockHeader []byte, compressed []byte) error {
+	headerSize := target.header.BlockHeaderSize()
+	_, err := target.file.Write(blockHeader[:headerSize])
+	if err != nil {
+		target.truncateTornBlock()
+		return err
+	}
+	_, err = target.file.Write(compressed)
+	if err != nil {
+		target.truncateTornBlock()
+		return err
+	}
+	offset := target.offset
+	target.offset += headerSize + int64(len(compressed))
+	target.index = target.index.append(offset, blockHeader)
+	_, err = target.indexFile.Write(target.index[len(target.index)-indexEntrySize:])
+	return err
+}
+
+func (target *dataFile) close() error {
+	err := target.file.Close()
+	indexErr := target.indexFile.Close()
+	if err != nil {
+		return err
+	}
+	return indexErr
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package evtstore
+
+import "errors"
+
+var freeDiskBytes = func(dir string) (int64, error) {
+	return 0, errors.New("free disk space is not supported on this platform")
+}
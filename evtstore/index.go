@@ -0,0 +1,188 @@
+package evtstore
+
+import (
+	"encoding/binary"
+	"os"
+	"path"
+	"sort"
+	"github.com/blang/vfs"
+	"github.com/v2pro/plz/countlog"
+)
+
+const indexEntrySize = 26
+const indexFileSuffix = ".idx"
+
+// blockIndexEntry locates one block in the data file, the ceilCTS is the largest maxCTS
+// of this block and all blocks before it, which keeps the index searchable
+// even if a block carries older events than its predecessor
+type blockIndexEntry []byte // offset(8byte)|compressedSize(4byte)|count(2byte)|minTimestamp(4byte)|maxTimestamp(4byte)|ceilTimestamp(4byte)
+
+func (entry blockIndexEntry) Offset() int64 {
+	return int64(binary.LittleEndian.Uint64(entry))
+}
+func (entry blockIndexEntry) CompressedSize() uint32 {
+	return binary.LittleEndian.Uint32(entry[8:])
+}
+func (entry blockIndexEntry) EntriesCount() uint16 {
+	return binary.LittleEndian.Uint16(entry[12:])
+}
+func (entry blockIndexEntry) MinCTS() uint32 {
+	return binary.LittleEndian.Uint32(entry[14:])
+}
+func (entry blockIndexEntry) MaxCTS() uint32 {
+	return binary.LittleEndian.Uint32(entry[18:])
+}
+func (entry blockIndexEntry) CeilCTS() uint32 {
+	return binary.LittleEndian.Uint32(entry[22:])
+}
+func (entry blockIndexEntry) End(headerSize int64) int64 {
+	return entry.Offset() + headerSize + int64(entry.CompressedSize())
+}
+
+type blockIndex []byte // blockIndexEntry|blockIndexEntry|...
+
+func (index blockIndex) Len() int {
+	return len(index) / indexEntrySize
+}
+
+func (index blockIndex) Entry(i int) blockIndexEntry {
+	return blockIndexEntry(index[i*indexEntrySize : (i+1)*indexEntrySize])
+}
+
+func (index blockIndex) ceilCTS() uint32 {
+	if index.Len() == 0 {
+		return 0
+	}
+	return index.Entry(index.Len() - 1).CeilCTS()
+}
+
+// append adds the block starting at offset, whose header is given
+func (index blockIndex) append(offset int64, header EventBlock) blockIndex {
+	var entry [indexEntrySize]byte
+	ceilCTS := index.ceilCTS()
+	if header.MaxCTS() > ceilCTS {
+		ceilCTS = header.MaxCTS()
+	}
+	binary.LittleEndian.PutUint64(entry[0:8], uint64(offset))
+	binary.LittleEndian.PutUint32(entry[8:12], header.CompressedSize())
+	binary.LittleEndian.PutUint16(entry[12:14], header.EntriesCount())
+	binary.LittleEndian.PutUint32(entry[14:18], header.MinCTS())
+	binary.LittleEndian.PutUint32(entry[18:22], header.MaxCTS())
+	binary.LittleEndian.PutUint32(entry[22:26], ceilCTS)
+	return append(index, entry[:]...)
+}
+
+// search returns the position of the first block which might contain events not before startCTS
+func (index blockIndex) search(startCTS uint32) int {
+	return sort.Search(index.Len(), func(i int) bool {
+		return index.Entry(i).CeilCTS() >= startCTS
+	})
+}
+
+// find returns the position of block starting at offset
+func (index blockIndex) find(offset int64) (int, bool) {
+	i := sort.Search(index.Len(), func(i int) bool {
+		return index.Entry(i).Offset() >= offset
+	})
+	if i < index.Len() && index.Entry(i).Offset() == offset {
+		return i, true
+	}
+	return 0, false
+}
+
+// covers tells if the index describes exactly the blocks of a data file of given size
+func (index blockIndex) covers(dataFileSize int64, header fileHeader) bool {
+	if len(index)%indexEntrySize != 0 {
+		return false
+	}
+	headerSize := header.BlockHeaderSize()
+	expectedOffset := header.Size()
+	ceilCTS := uint32(0)
+	for i := 0; i < index.Len(); i++ {
+		entry := index.Entry(i)
+		if entry.Offset() != expectedOffset {
+			return false
+		}
+		if entry.MaxCTS() > ceilCTS {
+			ceilCTS = entry.MaxCTS()
+		}
+		if entry.CeilCTS() != ceilCTS {
+			return false
+		}
+		expectedOffset = entry.End(headerSize)
+	}
+	return expectedOffset == dataFileSize
+}
+
+func indexFileName(fileName string) string {
+	return fileName + indexFileSuffix
+}
+
+// loadIndex reads the index of the data file, falling back to scan the data file
+// if the index is missing or does not match the data file
+func (store *Store) loadIndex(fileName string, file vfs.File, header fileHeader) (blockIndex, bool, error) {
+	stat, err := store.fs.Stat(path.Join(store.RootDir, fileName))
+	if err != nil {
+		return nil, false, err
+	}
+	content, err := vfs.ReadFile(store.fs, path.Join(store.RootDir, indexFileName(fileName)))
+	if err == nil && blockIndex(content).covers(stat.Size(), header) {
+		return blockIndex(content), false, nil
+	}
+	countlog.Info("event!store.index_invalid", "fileName", fileName, "err", err)
+	index, validSize, err := scanIndex(file, stat.Size(), header)
+	if err != nil {
+		return nil, false, err
+	}
+	if validSize < stat.Size() {
+		countlog.Info("event!store.ignored_torn_block", "fileName", fileName,
+			"offset", validSize, "droppedBytes", stat.Size()-validSize)
+	}
+	return index, true, nil
+}
+
+// scanIndex walks through the block headers of the data file to rebuild the index,
+// the returned size tells where the last complete block ends
+func scanIndex(file vfs.File, fileSize int64, fileHeader fileHeader) (blockIndex, int64, error) {
+	var headerBuf = [blockHeaderSize]byte{}
+	var header EventBlock = headerBuf[:]
+	var index blockIndex
+	headerSize := fileHeader.BlockHeaderSize()
+	offset := fileHeader.Size()
+	for offset+headerSize <= fileSize {
+		_, err := file.ReadAt(header, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		end := offset + headerSize + int64(header.CompressedSize())
+		if end > fileSize || !isWellFormed(header) {
+			break
+		}
+		index = index.append(offset, header)
+		offset = end
+	}
+	return index, offset, nil
+}
+
+// isWellFormed rejects the block header which can not be written by saveBlock, such as zeros
+func isWellFormed(header EventBlock) bool {
+	return header.CompressedSize() > 0 &&
+		header.EntriesCount() > 0 &&
+		header.UncompressedSize() >= uint32(header.EntriesCount())*entryHeaderSize &&
+		header.MinCTS() <= header.MaxCTS()
+}
+
+// saveIndex replaces the index file of the data file
+func (store *Store) saveIndex(fileName string, index blockIndex) error {
+	indexFilePath := path.Join(store.RootDir, indexFileName(fileName))
+	tmpFilePath := indexFilePath + ".tmp"
+	err := vfs.WriteFile(store.fs, tmpFilePath, index, 0666)
+	if err != nil {
+		return err
+	}
+	err = store.fs.Remove(indexFilePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return store.fs.Rename(tmpFilePath, indexFilePath)
+}
@@ -0,0 +1,102 @@
+package evtstore
+
+import (
+	"testing"
+	"github.com/stretchr/testify/require"
+	"github.com/blang/vfs"
+	"github.com/v2pro/quoll/timeutil"
+	"time"
+)
+
+func Test_index_written_per_block(t *testing.T) {
+	reset()
+	should := require.New(t)
+	var testStore = newTestStore()
+	should.Nil(testStore.Add([]byte(`{"url":"/hello1"}`)))
+	testStore.flushInputQueue()
+	should.Nil(testStore.Add([]byte(`{"url":"/hello2"}`)))
+	testStore.flushInputQueue()
+	content, err := vfs.ReadFile(fs, "/tmp/201701010000.idx")
+	should.Nil(err)
+	index := blockIndex(content)
+	should.Equal(2, index.Len())
+	should.Equal(int64(fileHeaderSize+ctsShiftSize), index.Entry(0).Offset())
+	should.Equal(index.Entry(0).End(blockHeaderSize+checksumSize), index.Entry(1).Offset())
+	should.Equal(uint16(1), index.Entry(1).EntriesCount())
+	stat, err := fs.Stat("/tmp/201701010000")
+	should.Nil(err)
+	should.True(index.covers(stat.Size(), newFileHeader(formatVersion, epoch, timeutil.DefaultShift)))
+}
+
+func Test_index_search(t *testing.T) {
+	should := require.New(t)
+	var index blockIndex
+	header := EventBlock(make([]byte, blockHeaderSize))
+	for _, cts := range [][2]uint32{{1, 5}, {6, 10}, {2, 3}, {11, 20}} {
+		copy(header[10:], []byte{byte(cts[0]), 0, 0, 0, byte(cts[1]), 0, 0, 0})
+		index = index.append(0, header)
+	}
+	should.Equal(0, index.search(0))
+	should.Equal(1, index.search(6))
+	should.Equal(1, index.search(10))
+	should.Equal(3, index.search(11))
+	should.Equal(4, index.search(21))
+}
+
+func Test_list_rebuild_missing_index(t *testing.T) {
+	reset()
+	should := require.New(t)
+	var testStore = newTestStore()
+	should.Nil(testStore.Add([]byte(`{"url":"/hello1"}`)))
+	testStore.flushInputQueue()
+	should.Nil(testStore.Add([]byte(`{"url":"/hello2"}`)))
+	testStore.flushInputQueue()
+	should.Nil(fs.Remove("/tmp/201701010000.idx"))
+	clock.Set(clock.Now().Add(time.Hour))
+	events, err := testStore.List(epoch, epoch.Add(time.Hour), 1, 1)
+	should.Nil(err)
+	blockId, block, _ := events.Next()
+	should.Equal(uint64(0x4F), blockId.Offset())
+	entries, err := block.Decompress()
+	should.Nil(err)
+	entry, _ := entries.Next()
+	should.Equal(`{"url":"/hello2"}`, string(entry.EventBody()))
+	content, err := vfs.ReadFile(fs, "/tmp/201701010000.idx")
+	should.Nil(err)
+	should.Equal(2, blockIndex(content).Len())
+}
+
+func Test_list_rebuild_corrupted_index(t *testing.T) {
+	reset()
+	should := require.New(t)
+	var testStore = newTestStore()
+	should.Nil(testStore.Add([]byte(`{"url":"/hello1"}`)))
+	testStore.flushInputQueue()
+	should.Nil(vfs.WriteFile(fs, "/tmp/201701010000.idx", []byte{1, 2, 3}, 0666))
+	events, err := testStore.List(epoch, epoch.Add(time.Hour), 0, 1)
+	should.Nil(err)
+	blockId, _, _ := events.Next()
+	should.Equal(uint64(0x1E), blockId.Offset())
+}
+
+func Test_reopen_existing_file(t *testing.T) {
+	reset()
+	should := require.New(t)
+	var testStore = newTestStore()
+	should.Nil(testStore.Add([]byte(`{"url":"/hello1"}`)))
+	testStore.flushInputQueue()
+	should.Nil(fs.Remove("/tmp/201701010000.idx"))
+	testStore = newTestStore()
+	should.Nil(testStore.Add([]byte(`{"url":"/hello2"}`)))
+	testStore.flushInputQueue()
+	content, err := vfs.ReadFile(fs, "/tmp/201701010000.idx")
+	should.Nil(err)
+	should.Equal(2, blockIndex(content).Len())
+	events, err := testStore.List(epoch, epoch.Add(time.Hour), 1, 1)
+	should.Nil(err)
+	_, block, _ := events.Next()
+	entries, err := block.Decompress()
+	should.Nil(err)
+	entry, _ := entries.Next()
+	should.Equal(`{"url":"/hello2"}`, string(entry.EventBody()))
+}
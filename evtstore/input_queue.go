@@ -0,0 +1,208 @@
+package evtstore
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"github.com/v2pro/quoll/discr"
+	"time"
+)
+
+// OverflowPolicy decides what Add does when the input queue is full
+type OverflowPolicy int
+
+const (
+	// OverflowReject fails the Add with ErrInputQueueOverflow
+	OverflowReject OverflowPolicy = iota
+	// OverflowBlock waits for the queue until the context of AddContext is done
+	OverflowBlock
+	// OverflowDropOldest drops the oldest queued event to make room for the new one
+	OverflowDropOldest
+)
+
+var ErrInputQueueOverflow = errors.New("input queue overflow")
+
+var ErrStoreClosed = errors.New("store closed")
+
+var ErrFutureEvent = errors.New("event timestamp is beyond current rotation interval")
+
+var ErrExpiredEvent = errors.New("event timestamp is before 1970 or older than MaxAge")
+
+// DroppedCounters counts the events lost by each overflow policy
+type DroppedCounters struct {
+	Rejected      uint64
+	TimedOut      uint64
+	DroppedOldest uint64
+}
+
+func (store *Store) Add(eventBody discr.EventBody) error {
+	return store.AddContext(context.Background(), eventBody)
+}
+
+// AddContext queues the event to be saved by the flushing goroutine,
+// the context only matters to OverflowBlock
+func (store *Store) AddContext(ctx context.Context, eventBody discr.EventBody) error {
+	return store.AddAtContext(ctx, store.clock.Now(), eventBody)
+}
+
+// AddAt adds the event happened at the given time, such as reported by client.
+// The late event is saved into the file of its own window.
+func (store *Store) AddAt(eventTS time.Time, eventBody discr.EventBody) error {
+	return store.AddAtContext(context.Background(), eventTS, eventBody)
+}
+
+// AddAtContext is AddAt waiting by the context when OverflowBlock. The timestamp must not be later than
+// the end of current window, otherwise the store would rotate ahead and treat the real-time events as late.
+// Neither can it be before 1970, which the file can not hold, or so old that the retention would remove it.
+func (store *Store) AddAtContext(ctx context.Context, eventTS time.Time, eventBody discr.EventBody) error {
+	now := store.clock.Now()
+	if store.windowOf(eventTS) > store.windowOf(now) {
+		return ErrFutureEvent
+	}
+	if eventTS.Before(time.Unix(0, 0)) ||
+		(store.Config.MaxAge > 0 && eventTS.Before(now.Add(-store.Config.MaxAge))) {
+		return ErrExpiredEvent
+	}
+	input := evtInput{
+		eventBody: eventBody,
+		eventTS:   eventTS,
+	}
+	for {
+		added, drained, err := store.tryAddInput(input)
+		if added || err != nil {
+			return err
+		}
+		// wait without addLock, so that AddAll and Close are not held up by the full queue
+		select {
+		case <-drained:
+		case <-ctx.Done():
+			atomic.AddUint64(&store.droppedCounters.TimedOut, 1)
+			return ctx.Err()
+		case <-store.stopping:
+			return ErrStoreClosed
+		}
+	}
+}
+
+// AddBatch adds the events one by one, the returned errors are nil for the added ones
+func (store *Store) AddBatch(ctx context.Context, eventBodies []discr.EventBody) []error {
+	errs := make([]error, len(eventBodies))
+	for i, eventBody := range eventBodies {
+		errs[i] = store.AddContext(ctx, eventBody)
+	}
+	return errs
+}
+
+// AddAll adds all the events or none of them. OverflowDropOldest makes room for the whole batch,
+// other policies fail with ErrInputQueueOverflow if there is no room, without waiting.
+func (store *Store) AddAll(eventBodies []discr.EventBody) error {
+	if len(eventBodies) > cap(store.inputQueue) {
+		atomic.AddUint64(&store.droppedCounters.Rejected, uint64(len(eventBodies)))
+		return ErrInputQueueOverflow
+	}
+	// the flushing goroutine only makes more room, while other adds are excluded
+	store.addLock.Lock()
+	defer store.addLock.Unlock()
+	if atomic.LoadInt32(&store.closed) == 1 {
+		return ErrStoreClosed
+	}
+	shortage := len(eventBodies) - (cap(store.inputQueue) - len(store.inputQueue))
+	if shortage > 0 && store.Config.OverflowPolicy != OverflowDropOldest {
+		atomic.AddUint64(&store.droppedCounters.Rejected, uint64(len(eventBodies)))
+		return ErrInputQueueOverflow
+	}
+	for i := 0; i < shortage; i++ {
+		select {
+		case <-store.inputQueue:
+			atomic.AddUint64(&store.droppedCounters.DroppedOldest, 1)
+		default:
+		}
+	}
+	now := store.clock.Now()
+	// never blocks, as there is room for all of them, which can only be made more by the flushing goroutine
+	for _, eventBody := range eventBodies {
+		store.inputQueue <- evtInput{
+			eventBody: eventBody,
+			eventTS:   now,
+		}
+	}
+	return nil
+}
+
+// tryAddInput adds the input without waiting. If the queue is full with OverflowBlock,
+// the returned channel is closed when the queue is drained to try again.
+func (store *Store) tryAddInput(input evtInput) (bool, <-chan struct{}, error) {
+	// checked under the lock, so that Close waits for the add or the add sees closed
+	store.addLock.RLock()
+	defer store.addLock.RUnlock()
+	if atomic.LoadInt32(&store.closed) == 1 {
+		return false, nil, ErrStoreClosed
+	}
+	select {
+	case store.inputQueue <- input:
+		return true, nil, nil
+	default:
+	}
+	switch store.Config.OverflowPolicy {
+	case OverflowBlock:
+		drained := store.queueDrained()
+		// the queue might be drained before the channel is taken
+		select {
+		case store.inputQueue <- input:
+			return true, nil, nil
+		default:
+		}
+		return false, drained, nil
+	case OverflowDropOldest:
+		for {
+			select {
+			case <-store.inputQueue:
+				atomic.AddUint64(&store.droppedCounters.DroppedOldest, 1)
+			default:
+			}
+			select {
+			case store.inputQueue <- input:
+				return true, nil, nil
+			default:
+			}
+		}
+	default:
+		atomic.AddUint64(&store.droppedCounters.Rejected, 1)
+		return false, nil, ErrInputQueueOverflow
+	}
+}
+
+// queueDrained is closed by notifyQueueDrained
+func (store *Store) queueDrained() <-chan struct{} {
+	store.drainedLock.Lock()
+	defer store.drainedLock.Unlock()
+	if store.drained == nil {
+		store.drained = make(chan struct{})
+	}
+	return store.drained
+}
+
+// notifyQueueDrained wakes up the adds waiting for the full queue, called by the flushing goroutine
+func (store *Store) notifyQueueDrained() {
+	store.drainedLock.Lock()
+	defer store.drainedLock.Unlock()
+	if store.drained != nil {
+		close(store.drained)
+		store.drained = nil
+	}
+}
+
+func (store *Store) DroppedCounters() DroppedCounters {
+	return DroppedCounters{
+		Rejected:      atomic.LoadUint64(&store.droppedCounters.Rejected),
+		TimedOut:      atomic.LoadUint64(&store.droppedCounters.TimedOut),
+		DroppedOldest: atomic.LoadUint64(&store.droppedCounters.DroppedOldest),
+	}
+}
+
+// resizeInputQueue applies Config.InputQueueCapacity, must be called before any Add
+func (store *Store) resizeInputQueue() {
+	if cap(store.inputQueue) != store.Config.InputQueueCapacity {
+		store.inputQueue = make(chan evtInput, store.Config.InputQueueCapacity)
+	}
+}
@@ -0,0 +1,167 @@
+package evtstore
+
+import (
+	"testing"
+	"github.com/stretchr/testify/require"
+	"context"
+	"time"
+	"github.com/v2pro/quoll/discr"
+)
+
+func newSmallQueueStore(policy OverflowPolicy) *Store {
+	var testStore = newTestStore()
+	testStore.Config.InputQueueCapacity = 2
+	testStore.Config.OverflowPolicy = policy
+	testStore.resizeInputQueue()
+	return testStore
+}
+
+func Test_overflow_reject(t *testing.T) {
+	reset()
+	should := require.New(t)
+	testStore := newSmallQueueStore(OverflowReject)
+	should.Nil(testStore.Add([]byte(`{"url":"/hello1"}`)))
+	should.Nil(testStore.Add([]byte(`{"url":"/hello2"}`)))
+	should.Equal(ErrInputQueueOverflow, testStore.Add([]byte(`{"url":"/hello3"}`)))
+	should.Equal(DroppedCounters{Rejected: 1}, testStore.DroppedCounters())
+}
+
+func Test_overflow_block(t *testing.T) {
+	reset()
+	should := require.New(t)
+	testStore := newSmallQueueStore(OverflowBlock)
+	should.Nil(testStore.Add([]byte(`{"url":"/hello1"}`)))
+	should.Nil(testStore.Add([]byte(`{"url":"/hello2"}`)))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	should.Equal(context.DeadlineExceeded, testStore.AddContext(ctx, []byte(`{"url":"/hello3"}`)))
+	should.Equal(DroppedCounters{TimedOut: 1}, testStore.DroppedCounters())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		testStore.flushInputQueue()
+	}()
+	should.Nil(testStore.AddContext(context.Background(), []byte(`{"url":"/hello3"}`)))
+}
+
+func Test_overflow_block_not_holding_up_add_all(t *testing.T) {
+	reset()
+	should := require.New(t)
+	testStore := newSmallQueueStore(OverflowBlock)
+	should.Nil(testStore.Add([]byte(`{"url":"/hello1"}`)))
+	should.Nil(testStore.Add([]byte(`{"url":"/hello2"}`)))
+	blocked := make(chan error, 1)
+	go func() {
+		blocked <- testStore.Add([]byte(`{"url":"/hello3"}`))
+	}()
+	time.Sleep(10 * time.Millisecond)
+	// the waiting add does not keep the lock, AddAll fails without waiting
+	addAllDone := make(chan error, 1)
+	go func() {
+		addAllDone <- testStore.AddAll([]discr.EventBody{[]byte(`{"url":"/hello4"}`)})
+	}()
+	select {
+	case err := <-addAllDone:
+		should.Equal(ErrInputQueueOverflow, err)
+	case <-time.After(time.Second):
+		should.Fail("AddAll held up by the waiting add")
+	}
+	testStore.flushInputQueue()
+	select {
+	case err := <-blocked:
+		should.Nil(err)
+	case <-time.After(time.Second):
+		should.Fail("the waiting add not woken up by flush")
+	}
+	testStore.flushInputQueue()
+	iter, err := testStore.Query(epoch, epoch.Add(time.Hour), 0, 10)
+	should.Nil(err)
+	should.Equal(`{"url":"/hello1"}`, string(iter.Next().Body))
+	should.Equal(`{"url":"/hello2"}`, string(iter.Next().Body))
+	should.Equal(`{"url":"/hello3"}`, string(iter.Next().Body))
+	should.False(iter.HasNext())
+}
+
+func Test_overflow_drop_oldest(t *testing.T) {
+	reset()
+	should := require.New(t)
+	testStore := newSmallQueueStore(OverflowDropOldest)
+	should.Nil(testStore.Add([]byte(`{"url":"/hello1"}`)))
+	should.Nil(testStore.Add([]byte(`{"url":"/hello2"}`)))
+	should.Nil(testStore.Add([]byte(`{"url":"/hello3"}`)))
+	should.Equal(DroppedCounters{DroppedOldest: 1}, testStore.DroppedCounters())
+	testStore.flushInputQueue()
+	iter, err := testStore.Query(epoch, epoch.Add(time.Hour), 0, 10)
+	should.Nil(err)
+	should.Equal(`{"url":"/hello2"}`, string(iter.Next().Body))
+	should.Equal(`{"url":"/hello3"}`, string(iter.Next().Body))
+	should.False(iter.HasNext())
+}
+
+func Test_add_all(t *testing.T) {
+	reset()
+	should := require.New(t)
+	testStore := newSmallQueueStore(OverflowReject)
+	should.Nil(testStore.Add([]byte(`{"url":"/hello1"}`)))
+	should.Equal(ErrInputQueueOverflow, testStore.AddAll([]discr.EventBody{
+		[]byte(`{"url":"/hello2"}`), []byte(`{"url":"/hello3"}`)}))
+	should.Equal(DroppedCounters{Rejected: 2}, testStore.DroppedCounters())
+	should.Len(testStore.inputQueue, 1)
+	testStore.flushInputQueue()
+	should.Nil(testStore.AddAll([]discr.EventBody{
+		[]byte(`{"url":"/hello2"}`), []byte(`{"url":"/hello3"}`)}))
+	should.Len(testStore.inputQueue, 2)
+}
+
+func Test_add_all_drop_oldest(t *testing.T) {
+	reset()
+	should := require.New(t)
+	testStore := newSmallQueueStore(OverflowDropOldest)
+	should.Nil(testStore.Add([]byte(`{"url":"/hello1"}`)))
+	should.Nil(testStore.AddAll([]discr.EventBody{
+		[]byte(`{"url":"/hello2"}`), []byte(`{"url":"/hello3"}`)}))
+	should.Equal(DroppedCounters{DroppedOldest: 1}, testStore.DroppedCounters())
+	should.Equal(ErrInputQueueOverflow, testStore.AddAll([]discr.EventBody{
+		[]byte(`{"url":"/hello4"}`), []byte(`{"url":"/hello5"}`), []byte(`{"url":"/hello6"}`)}))
+}
+
+func Test_add_batch(t *testing.T) {
+	reset()
+	should := require.New(t)
+	testStore := newSmallQueueStore(OverflowReject)
+	errs := testStore.AddBatch(context.Background(), []discr.EventBody{
+		[]byte(`{"url":"/hello1"}`), []byte(`{"url":"/hello2"}`), []byte(`{"url":"/hello3"}`)})
+	should.Equal([]error{nil, nil, ErrInputQueueOverflow}, errs)
+}
+
+func Test_add_racing_close(t *testing.T) {
+	reset()
+	should := require.New(t)
+	testStore := newTestStore()
+	testStore.Config.OverflowPolicy = OverflowBlock
+	should.Nil(testStore.Start())
+	added := make(chan int, 8)
+	for i := 0; i < 8; i++ {
+		go func() {
+			count := 0
+			for testStore.Add([]byte(`{"url":"/hello"}`)) == nil {
+				count++
+			}
+			added <- count
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	should.Nil(testStore.Close(context.Background()))
+	total := 0
+	for i := 0; i < 8; i++ {
+		total += <-added
+	}
+	// every add returned nil is saved
+	iter, err := testStore.Query(epoch, epoch.Add(time.Hour), 0, total+1)
+	should.Nil(err)
+	saved := 0
+	for iter.HasNext() {
+		iter.Next()
+		saved++
+	}
+	should.Equal(total, saved)
+}
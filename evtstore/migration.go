@@ -0,0 +1,62 @@
+package evtstore
+
+import (
+	"os"
+	"path"
+	"github.com/v2pro/plz/countlog"
+)
+
+// migrateFileNames renames the files named in another time zone, such as the files named in
+// Asia/Shanghai by previous versions. The base time in file header tells when the file starts.
+func (store *Store) migrateFileNames() error {
+	files, err := store.dataFiles()
+	if err != nil {
+		return err
+	}
+	for _, fileInfo := range files {
+		fileName := fileInfo.Name()
+		header, err := store.readFileHeaderOf(fileName)
+		if err != nil {
+			// let repairFile or the readers deal with it
+			countlog.Debug("event!store.skipped_migrating_file", "fileName", fileName, "err", err)
+			continue
+		}
+		newFileName := store.fileNameOf(header.BaseTime())
+		if newFileName == fileName {
+			continue
+		}
+		newFilePath := path.Join(store.RootDir, newFileName)
+		if _, err := store.fs.Stat(newFilePath); err == nil {
+			countlog.Error("event!store.failed to migrate file name, target exists",
+				"fileName", fileName, "newFileName", newFileName)
+			continue
+		}
+		err = store.fs.Rename(path.Join(store.RootDir, fileName), newFilePath)
+		if err != nil {
+			return err
+		}
+		// the index and scene stats are named after the data file
+		companions := [][2]string{
+			{indexFileName(fileName), indexFileName(newFileName)},
+			{sceneStatsFileName(fileName), sceneStatsFileName(newFileName)},
+			{sceneStatsFileName(fileName) + ".tmp", sceneStatsFileName(newFileName) + ".tmp"},
+		}
+		for _, companion := range companions {
+			err = store.fs.Rename(path.Join(store.RootDir, companion[0]), path.Join(store.RootDir, companion[1]))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		countlog.Info("event!store.migrated_file_name", "fileName", fileName, "newFileName", newFileName)
+	}
+	return nil
+}
+
+func (store *Store) readFileHeaderOf(fileName string) (fileHeader, error) {
+	file, err := store.fs.OpenFile(path.Join(store.RootDir, fileName), os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return readFileHeader(file)
+}
@@ -0,0 +1,50 @@
+package evtstore
+
+import (
+	"testing"
+	"github.com/stretchr/testify/require"
+	"time"
+	"os"
+	"github.com/blang/vfs"
+)
+
+func Test_migrate_file_names_from_shanghai(t *testing.T) {
+	reset()
+	should := require.New(t)
+	// named in Asia/Shanghai by previous versions
+	writeVersion1File(should, "/tmp/201701010800", []byte(`{"url":"/hello1"}`))
+	should.Nil(vfs.WriteFile(fs, "/tmp/201701010800.scenes", []byte(`[]`), 0666))
+	var testStore = newTestStore()
+	should.Nil(testStore.migrateFileNames())
+	dir := dataFiles()
+	should.Len(dir, 1)
+	should.Equal("201701010000", dir[0].Name())
+	// the scene stats follow the data file
+	content, err := vfs.ReadFile(fs, "/tmp/201701010000.scenes")
+	should.Nil(err)
+	should.Equal(`[]`, string(content))
+	_, err = fs.Stat("/tmp/201701010800.scenes")
+	should.True(os.IsNotExist(err))
+	events, err := testStore.List(epoch, epoch.Add(time.Hour), 0, 10)
+	should.Nil(err)
+	blockId, _, _ := events.Next()
+	should.Equal("201701010000", blockId.FileName())
+}
+
+func Test_file_name_in_location(t *testing.T) {
+	reset()
+	should := require.New(t)
+	var testStore = newTestStore()
+	testStore.Config.Location = time.FixedZone("UTC+8", 8*3600)
+	should.Nil(testStore.Add([]byte(`{"url":"/hello1"}`)))
+	testStore.flushInputQueue()
+	dir := dataFiles()
+	should.Len(dir, 1)
+	should.Equal("201701010800", dir[0].Name())
+	should.Nil(testStore.migrateFileNames())
+	should.Equal("201701010800", dataFiles()[0].Name())
+	events, err := testStore.List(epoch, epoch.Add(time.Hour), 0, 10)
+	should.Nil(err)
+	blockId, _, _ := events.Next()
+	should.Equal("201701010800", blockId.FileName())
+}
@@ -0,0 +1,203 @@
+package evtstore
+
+import (
+	"github.com/v2pro/plz/countlog"
+	"github.com/v2pro/quoll/discr"
+	"time"
+	"errors"
+	"path"
+	"os"
+	"fmt"
+)
+
+type Event struct {
+	BlockId    EventBlockId
+	Timestamp  time.Time
+	Body       discr.EventBody
+	entryIndex uint32
+}
+
+// Cursor is positioned after the event, to continue the query by QueryAfter
+func (event Event) Cursor() Cursor {
+	return NewCursor(event.BlockId, event.entryIndex+1)
+}
+
+// EventIterator yields the events found by Query, in the order they are stored
+type EventIterator struct {
+	events []Event
+}
+
+func (iter *EventIterator) HasNext() bool {
+	return len(iter.events) > 0
+}
+
+func (iter *EventIterator) Next() Event {
+	if len(iter.events) == 0 {
+		panic("no more event")
+	}
+	event := iter.events[0]
+	iter.events = iter.events[1:]
+	return event
+}
+
+// Query decodes the events stored between startTime and endTime (both inclusive),
+// skip and limit are counted by events within the time range
+func (store *Store) Query(startTime time.Time, endTime time.Time, skip int, limit int) (*EventIterator, error) {
+	iter, _, err := store.QueryAfter(nil, startTime, endTime, skip, limit)
+	return iter, err
+}
+
+// QueryAfter continues the query from the cursor returned by previous page,
+// the returned cursor is positioned after the last returned event
+func (store *Store) QueryAfter(cursor Cursor, startTime time.Time, endTime time.Time,
+	skip int, limit int) (*EventIterator, Cursor, error) {
+	collector := &eventsCollector{
+		cursor:    cursor,
+		startTime: startTime,
+		endTime:   endTime,
+		skip:      skip,
+		limit:     limit,
+	}
+	if limit > 0 {
+		err := store.walkBlocks(startTime, endTime, cursor, collector)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	nextCursor := cursor
+	if len(collector.events) > 0 {
+		nextCursor = collector.events[len(collector.events)-1].Cursor()
+	}
+	return &EventIterator{events: collector.events}, nextCursor, nil
+}
+
+type eventsCollector struct {
+	cursor       Cursor
+	startTime    time.Time
+	endTime      time.Time
+	skip         int
+	limit        int
+	skippedCount int
+	events       []Event
+}
+
+func (collector *eventsCollector) skipBlock(blockId EventBlockId, header fileHeader, entry blockIndexEntry) bool {
+	if collector.cursor.consumed(blockId, entry) {
+		return true
+	}
+	entriesCount := int(entry.EntriesCount())
+	if collector.cursor.at(blockId) {
+		// the entries before the cursor are returned by previous page, not to be skipped again
+		entriesCount -= int(collector.cursor.EntryIndex())
+	}
+	remaining := collector.skip - collector.skippedCount
+	if remaining < entriesCount {
+		return false
+	}
+	// only the block within the time range can be skipped as a whole without counting event by event
+	minTime := header.Decompress(entry.MinCTS())
+	maxTime := header.Decompress(entry.MaxCTS())
+	if minTime.Before(collector.startTime) || maxTime.After(collector.endTime) {
+		return false
+	}
+	collector.skippedCount += entriesCount
+	return true
+}
+
+func (collector *eventsCollector) visitBlock(blockId EventBlockId, block EventBlock, header fileHeader) (bool, error) {
+	events, err := decodeBlock(blockId, block, header)
+	if err != nil {
+		countlog.Error("event!store.skipped_undecompressible_block", "err", err,
+			"fileName", blockId.FileName(), "offset", blockId.Offset())
+		return false, nil
+	}
+	if collector.cursor.at(blockId) {
+		events = events[collector.cursor.EntryIndex():]
+	}
+	for _, event := range events {
+		if event.Timestamp.Before(collector.startTime) || event.Timestamp.After(collector.endTime) {
+			continue
+		}
+		if collector.skippedCount < collector.skip {
+			collector.skippedCount++
+			continue
+		}
+		collector.events = append(collector.events, event)
+		if len(collector.events) >= collector.limit {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func decodeBlock(blockId EventBlockId, block EventBlock, header fileHeader) ([]Event, error) {
+	entries, err := block.Decompress()
+	if err != nil {
+		return nil, err
+	}
+	events := make([]Event, 0, block.EntriesCount())
+	for len(entries) > 0 {
+		var entry EventEntry
+		entry, entries = entries.Next()
+		events = append(events, Event{
+			BlockId:    blockId,
+			Timestamp:  header.Decompress(entry.EventCTS()),
+			Body:       entry.EventBody(),
+			entryIndex: uint32(len(events)),
+		})
+	}
+	return events, nil
+}
+
+// GetBlock reads the block again by the id returned from List
+func (store *Store) GetBlock(blockId EventBlockId) (EventBlock, error) {
+	block, _, err := store.getBlock(blockId)
+	return block, err
+}
+
+// GetBlockEvents reads the block again by the id returned from List, and decodes its events
+func (store *Store) GetBlockEvents(blockId EventBlockId) (*EventIterator, error) {
+	block, header, err := store.getBlock(blockId)
+	if err != nil {
+		return nil, err
+	}
+	events, err := decodeBlock(blockId, block, header)
+	if err != nil {
+		return nil, err
+	}
+	return &EventIterator{events: events}, nil
+}
+
+func (store *Store) getBlock(blockId EventBlockId) (EventBlock, fileHeader, error) {
+	if len(blockId) != blockIdSize {
+		return nil, nil, errors.New("invalid block id size")
+	}
+	fileName := blockId.FileName()
+	if !isDataFileName(fileName) {
+		return nil, nil, errors.New("invalid file name in block id: " + fileName)
+	}
+	file, err := store.fs.OpenFile(path.Join(store.RootDir, fileName), os.O_RDONLY, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	fileHeader, err := readFileHeader(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	index, _, err := store.loadIndex(fileName, file, fileHeader)
+	if err != nil {
+		return nil, nil, err
+	}
+	pos, found := index.find(int64(blockId.Offset()) - fileHeader.BlockHeaderSize())
+	if !found {
+		return nil, nil, fmt.Errorf("offset %d is not at block boundary", blockId.Offset())
+	}
+	entry := index.Entry(pos)
+	buf := make([]byte, fileHeader.BlockHeaderSize()+int64(entry.CompressedSize()))
+	block, err := readBlock(file, fileHeader, entry, buf)
+	if err != nil {
+		return nil, nil, err
+	}
+	return block, fileHeader, nil
+}
@@ -0,0 +1,107 @@
+package evtstore
+
+import (
+	"testing"
+	"github.com/stretchr/testify/require"
+	"time"
+)
+
+func Test_query(t *testing.T) {
+	reset()
+	should := require.New(t)
+	var testStore = newTestStore()
+	should.Nil(testStore.Add([]byte(`{"url":"/hello1"}`)))
+	clock.Set(epoch.Add(time.Minute))
+	should.Nil(testStore.Add([]byte(`{"url":"/hello2"}`)))
+	testStore.flushInputQueue()
+	clock.Set(epoch.Add(time.Minute * 2))
+	should.Nil(testStore.Add([]byte(`{"url":"/hello3"}`)))
+	testStore.flushInputQueue()
+	iter, err := testStore.Query(epoch, epoch.Add(time.Hour), 0, 10)
+	should.Nil(err)
+	should.True(iter.HasNext())
+	event := iter.Next()
+	should.Equal(`{"url":"/hello1"}`, string(event.Body))
+	should.Equal(epoch.Unix(), event.Timestamp.Unix())
+	should.Equal("201701010000", event.BlockId.FileName())
+	event = iter.Next()
+	should.Equal(`{"url":"/hello2"}`, string(event.Body))
+	should.Equal(epoch.Add(time.Minute).Unix(), event.Timestamp.Unix())
+	event = iter.Next()
+	should.Equal(`{"url":"/hello3"}`, string(event.Body))
+	should.False(iter.HasNext())
+}
+
+func Test_query_filter_events_within_block(t *testing.T) {
+	reset()
+	should := require.New(t)
+	var testStore = newTestStore()
+	should.Nil(testStore.Add([]byte(`{"url":"/hello1"}`)))
+	clock.Set(epoch.Add(time.Minute))
+	should.Nil(testStore.Add([]byte(`{"url":"/hello2"}`)))
+	clock.Set(epoch.Add(time.Minute * 2))
+	should.Nil(testStore.Add([]byte(`{"url":"/hello3"}`)))
+	testStore.flushInputQueue()
+	iter, err := testStore.Query(epoch.Add(time.Second), epoch.Add(time.Minute+time.Second), 0, 10)
+	should.Nil(err)
+	event := iter.Next()
+	should.Equal(`{"url":"/hello2"}`, string(event.Body))
+	should.False(iter.HasNext())
+}
+
+func Test_query_skip_and_limit(t *testing.T) {
+	reset()
+	should := require.New(t)
+	var testStore = newTestStore()
+	should.Nil(testStore.Add([]byte(`{"url":"/hello1"}`)))
+	should.Nil(testStore.Add([]byte(`{"url":"/hello2"}`)))
+	testStore.flushInputQueue()
+	should.Nil(testStore.Add([]byte(`{"url":"/hello3"}`)))
+	should.Nil(testStore.Add([]byte(`{"url":"/hello4"}`)))
+	testStore.flushInputQueue()
+	iter, err := testStore.Query(epoch, epoch.Add(time.Hour), 1, 2)
+	should.Nil(err)
+	should.Equal(`{"url":"/hello2"}`, string(iter.Next().Body))
+	should.Equal(`{"url":"/hello3"}`, string(iter.Next().Body))
+	should.False(iter.HasNext())
+	iter, err = testStore.Query(epoch, epoch.Add(time.Hour), 2, 10)
+	should.Nil(err)
+	should.Equal(`{"url":"/hello3"}`, string(iter.Next().Body))
+	should.Equal(`{"url":"/hello4"}`, string(iter.Next().Body))
+	should.False(iter.HasNext())
+}
+
+func Test_get_block(t *testing.T) {
+	reset()
+	should := require.New(t)
+	var testStore = newTestStore()
+	should.Nil(testStore.Add([]byte(`{"url":"/hello1"}`)))
+	testStore.flushInputQueue()
+	should.Nil(testStore.Add([]byte(`{"url":"/hello2"}`)))
+	testStore.flushInputQueue()
+	block, err := testStore.GetBlock(NewEventBlockId("201701010000", 0x4F))
+	should.Nil(err)
+	should.Equal(uint16(1), block.EntriesCount())
+	entries, err := block.Decompress()
+	should.Nil(err)
+	entry, _ := entries.Next()
+	should.Equal(`{"url":"/hello2"}`, string(entry.EventBody()))
+	iter, err := testStore.GetBlockEvents(NewEventBlockId("201701010000", 0x1E))
+	should.Nil(err)
+	event := iter.Next()
+	should.Equal(`{"url":"/hello1"}`, string(event.Body))
+	should.Equal(epoch.Unix(), event.Timestamp.Unix())
+	should.False(iter.HasNext())
+}
+
+func Test_get_block_not_at_boundary(t *testing.T) {
+	reset()
+	should := require.New(t)
+	var testStore = newTestStore()
+	should.Nil(testStore.Add([]byte(`{"url":"/hello1"}`)))
+	testStore.flushInputQueue()
+	_, err := testStore.GetBlock(NewEventBlockId("201701010000", 0x1F))
+	should.NotNil(err)
+	_, err = testStore.GetBlock(NewEventBlockId("../../etc/pa", 0x1D))
+	should.NotNil(err)
+}
@@ -0,0 +1,98 @@
+package evtstore
+
+import (
+	"os"
+	"path"
+	"github.com/v2pro/plz/countlog"
+)
+
+// repairLatestFile checks the file written before last shutdown,
+// the agent might died in the middle of saveBlock
+func (store *Store) repairLatestFile() error {
+	files, err := store.dataFiles()
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	_, _, err = store.repairFile(files[len(files)-1].Name())
+	if err != nil {
+		return err
+	}
+	store.repairedFiles[files[len(files)-1].Name()] = true
+	return nil
+}
+
+// repairFile truncates the torn blocks at the tail of data file, and rewrites its index.
+// A file without complete file header is removed, as it contains no block.
+func (store *Store) repairFile(fileName string) (fileHeader, blockIndex, error) {
+	filePath := path.Join(store.RootDir, fileName)
+	file, err := store.fs.OpenFile(filePath, os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	stat, err := store.fs.Stat(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	fileSize := stat.Size()
+	fileHeader, err := readFileHeader(file)
+	if err == errTornFileHeader {
+		countlog.Info("event!store.removed_torn_file", "fileName", fileName, "droppedBytes", fileSize)
+		err = store.fs.Remove(filePath)
+		if err != nil {
+			return nil, nil, err
+		}
+		err = store.fs.Remove(path.Join(store.RootDir, indexFileName(fileName)))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, nil, err
+		}
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	index, validSize, err := scanIndex(file, fileSize, fileHeader)
+	if err != nil {
+		return nil, nil, err
+	}
+	// the body of last block might be written partially even if its size looks right
+	for index.Len() > 0 {
+		lastEntry := index.Entry(index.Len() - 1)
+		err = verifyBlock(file, fileHeader, lastEntry)
+		if err == nil {
+			break
+		}
+		countlog.Info("event!store.found_corrupted_block", "fileName", fileName,
+			"offset", lastEntry.Offset(), "err", err)
+		validSize = lastEntry.Offset()
+		index = index[:len(index)-indexEntrySize]
+	}
+	if validSize < fileSize {
+		err = file.Truncate(validSize)
+		if err != nil {
+			return nil, nil, err
+		}
+		countlog.Info("event!store.truncated_torn_block", "fileName", fileName,
+			"offset", validSize, "droppedBytes", fileSize-validSize)
+	}
+	err = store.saveIndex(fileName, index)
+	if err != nil {
+		return nil, nil, err
+	}
+	return fileHeader, index, nil
+}
+
+// truncateTornBlock removes the partially written block after appendBlock failed,
+// so that next block can still be appended at a block boundary
+func (target *dataFile) truncateTornBlock() {
+	err := target.file.Truncate(target.offset)
+	if err != nil {
+		countlog.Error("event!failed to truncate torn block", "err", err,
+			"fileName", target.file.Name(), "offset", target.offset)
+		return
+	}
+	countlog.Info("event!store.truncated_torn_block", "fileName", target.file.Name(), "offset", target.offset)
+}
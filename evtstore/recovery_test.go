@@ -0,0 +1,128 @@
+package evtstore
+
+import (
+	"testing"
+	"github.com/stretchr/testify/require"
+	"github.com/blang/vfs"
+	"os"
+	"time"
+)
+
+func Test_repair_torn_block_header(t *testing.T) {
+	reset()
+	should := require.New(t)
+	var testStore = newTestStore()
+	should.Nil(testStore.Add([]byte(`{"url":"/hello1"}`)))
+	testStore.flushInputQueue()
+	appendBytes(should, "/tmp/201701010000", []byte{1, 2, 3})
+	should.Nil(testStore.repairLatestFile())
+	stat, err := fs.Stat("/tmp/201701010000")
+	should.Nil(err)
+	should.Equal(int64(0x1E+27), stat.Size())
+	content, err := vfs.ReadFile(fs, "/tmp/201701010000.idx")
+	should.Nil(err)
+	should.Equal(1, blockIndex(content).Len())
+}
+
+func Test_repair_torn_block_body(t *testing.T) {
+	reset()
+	should := require.New(t)
+	var testStore = newTestStore()
+	should.Nil(testStore.Add([]byte(`{"url":"/hello1"}`)))
+	testStore.flushInputQueue()
+	should.Nil(testStore.Add([]byte(`{"url":"/hello2"}`)))
+	testStore.flushInputQueue()
+	should.Nil(fs.Remove("/tmp/201701010000.idx"))
+	file, err := fs.OpenFile("/tmp/201701010000", os.O_RDWR, 0)
+	should.Nil(err)
+	should.Nil(file.Truncate(0x4F + 10))
+	should.Nil(file.Close())
+	should.Nil(testStore.repairLatestFile())
+	stat, err := fs.Stat("/tmp/201701010000")
+	should.Nil(err)
+	should.Equal(int64(0x4F-blockHeaderSize-checksumSize), stat.Size())
+}
+
+func Test_repair_undecompressible_block(t *testing.T) {
+	reset()
+	should := require.New(t)
+	var testStore = newTestStore()
+	should.Nil(testStore.Add([]byte(`{"url":"/hello1"}`)))
+	testStore.flushInputQueue()
+	should.Nil(testStore.Add([]byte(`{"url":"/hello2"}`)))
+	testStore.flushInputQueue()
+	file, err := fs.OpenFile("/tmp/201701010000", os.O_RDWR, 0)
+	should.Nil(err)
+	_, err = file.Seek(0x4F, 0)
+	should.Nil(err)
+	_, err = file.Write([]byte{0xFF, 0xFF, 0xFF})
+	should.Nil(err)
+	should.Nil(file.Close())
+	should.Nil(testStore.repairLatestFile())
+	stat, err := fs.Stat("/tmp/201701010000")
+	should.Nil(err)
+	should.Equal(int64(0x4F-blockHeaderSize-checksumSize), stat.Size())
+}
+
+func Test_repair_torn_file_header(t *testing.T) {
+	reset()
+	should := require.New(t)
+	should.Nil(vfs.WriteFile(fs, "/tmp/201701010000", []byte{0xD1, 0xD1}, 0666))
+	var testStore = newTestStore()
+	should.Nil(testStore.repairLatestFile())
+	should.Len(dataFiles(), 0)
+}
+
+func Test_append_after_repair(t *testing.T) {
+	reset()
+	should := require.New(t)
+	var testStore = newTestStore()
+	should.Nil(testStore.Add([]byte(`{"url":"/hello1"}`)))
+	testStore.flushInputQueue()
+	appendBytes(should, "/tmp/201701010000", []byte{1, 2, 3, 4, 5})
+	testStore = newTestStore()
+	should.Nil(testStore.Add([]byte(`{"url":"/hello2"}`)))
+	testStore.flushInputQueue()
+	events, err := testStore.List(epoch, epoch.Add(time.Hour), 1, 1)
+	should.Nil(err)
+	blockId, block, _ := events.Next()
+	should.Equal(uint64(0x4F), blockId.Offset())
+	entries, err := block.Decompress()
+	should.Nil(err)
+	entry, _ := entries.Next()
+	should.Equal(`{"url":"/hello2"}`, string(entry.EventBody()))
+}
+
+func Test_repair_once_since_started(t *testing.T) {
+	reset()
+	should := require.New(t)
+	var testStore = newTestStore()
+	should.Nil(testStore.Add([]byte(`{"url":"/hello1"}`)))
+	testStore.flushInputQueue()
+	should.Nil(testStore.Add([]byte(`{"url":"/hello2"}`)))
+	testStore.flushInputQueue()
+	file, err := fs.OpenFile("/tmp/201701010000", os.O_RDWR, 0)
+	should.Nil(err)
+	_, err = file.Seek(0x4F, 0)
+	should.Nil(err)
+	_, err = file.Write([]byte{0xFF, 0xFF, 0xFF})
+	should.Nil(err)
+	should.Nil(file.Close())
+	clock.Set(epoch.Add(time.Hour))
+	should.Nil(testStore.Add([]byte(`{"url":"/hello3"}`)))
+	testStore.flushInputQueue()
+	// the late event reopens the file written since started, which is not scanned again
+	should.Nil(testStore.AddAt(epoch.Add(time.Minute), []byte(`{"url":"/hello4"}`)))
+	testStore.flushInputQueue()
+	content, err := vfs.ReadFile(fs, "/tmp/201701010000.idx")
+	should.Nil(err)
+	should.Equal(3, blockIndex(content).Len())
+}
+
+func appendBytes(should *require.Assertions, filePath string, content []byte) {
+	file, err := fs.OpenFile(filePath, os.O_WRONLY|os.O_APPEND, 0)
+	should.Nil(err)
+	_, err = file.Write(content)
+	should.Nil(err)
+	should.Nil(file.Close())
+}
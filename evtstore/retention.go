@@ -0,0 +1,107 @@
+package evtstore
+
+import (
+	"os"
+	"path"
+	"github.com/v2pro/plz/countlog"
+)
+
+// clean removes the oldest files, until the retention policies in config are all satisfied
+func (store *Store) clean() {
+	defer func() {
+		recovered := recover()
+		if recovered != nil {
+			countlog.Fatal("event!store.clean.panic", "err", recovered,
+				"stacktrace", countlog.ProvideStacktrace)
+		}
+	}()
+	files, err := store.dataFiles()
+	if err != nil {
+		countlog.Error("event!failed to read dir", "err", err, "rootDir", store.RootDir)
+		return
+	}
+	sizes := make([]int64, len(files))
+	totalBytes := int64(0)
+	for i, file := range files {
+		sizes[i] = file.Size() + store.indexFileSize(file.Name()) + store.sceneStatsFileSize(file.Name())
+		totalBytes += sizes[i]
+	}
+	freeBytes := int64(-1) // unknown
+	if store.Config.MinFreeDiskBytes > 0 {
+		freeBytes, err = freeDiskBytes(store.RootDir)
+		if err != nil {
+			countlog.Error("event!failed to get free disk space", "err", err, "rootDir", store.RootDir)
+			freeBytes = -1
+		}
+	}
+	// the latest file is being written
+	for i := 0; i < len(files)-1; i++ {
+		reason := store.retentionReason(files, i, totalBytes, freeBytes)
+		if reason == "" {
+			return
+		}
+		filePath := path.Join(store.RootDir, files[i].Name())
+		err := store.fs.Remove(filePath)
+		if err != nil {
+			countlog.Error("event!failed to clean old file", "err", err, "filePath", filePath)
+			continue
+		}
+		countlog.Info("event!cleaned_old_file", "filePath", filePath, "reason", reason)
+		delete(store.repairedFiles, files[i].Name())
+		indexFilePath := path.Join(store.RootDir, indexFileName(files[i].Name()))
+		err = store.fs.Remove(indexFilePath)
+		if err != nil && !os.IsNotExist(err) {
+			countlog.Error("event!failed to clean old index file", "err", err, "filePath", indexFilePath)
+		}
+		sceneStatsFilePath := path.Join(store.RootDir, sceneStatsFileName(files[i].Name()))
+		for _, filePath := range []string{sceneStatsFilePath, sceneStatsFilePath + ".tmp"} {
+			err = store.fs.Remove(filePath)
+			if err != nil && !os.IsNotExist(err) {
+				countlog.Error("event!failed to clean old scene stats file", "err", err, "filePath", filePath)
+			}
+		}
+		totalBytes -= sizes[i]
+		if freeBytes >= 0 {
+			freeBytes += sizes[i]
+		}
+	}
+}
+
+// retentionReason tells why the i-th file should be removed, or empty if it should be kept.
+// As the files are checked from the oldest, the files after the kept one are kept as well.
+func (store *Store) retentionReason(files []os.FileInfo, i int, totalBytes int64, freeBytes int64) string {
+	config := &store.Config
+	if config.KeepFilesCount > 0 && len(files)-i > config.KeepFilesCount {
+		return "exceeded KeepFilesCount"
+	}
+	if config.MaxAge > 0 {
+		// the file ends where next file starts
+		nextFileTime, _ := store.fileTimeOf(files[i+1].Name())
+		if nextFileTime.Before(store.clock.Now().Add(-config.MaxAge)) {
+			return "exceeded MaxAge"
+		}
+	}
+	if config.MaxTotalBytes > 0 && totalBytes > config.MaxTotalBytes {
+		return "exceeded MaxTotalBytes"
+	}
+	if freeBytes >= 0 && freeBytes < config.MinFreeDiskBytes {
+		return "below MinFreeDiskBytes"
+	}
+	return ""
+}
+
+func (store *Store) indexFileSize(fileName string) int64 {
+	stat, err := store.fs.Stat(path.Join(store.RootDir, indexFileName(fileName)))
+	if err != nil {
+		return 0
+	}
+	return stat.Size()
+}
+
+func (store *Store) sceneStatsFileSize(fileName string) int64 {
+	stat, err := store.fs.Stat(path.Join(store.RootDir, sceneStatsFileName(fileName)))
+	if err != nil {
+		return 0
+	}
+	return stat.Size()
+}
@@ -0,0 +1,77 @@
+package evtstore
+
+import (
+	"testing"
+	"github.com/stretchr/testify/require"
+	"github.com/blang/vfs"
+	"time"
+)
+
+func addHourlyFiles(should *require.Assertions, testStore *Store, hoursCount int) {
+	for i := 0; i < hoursCount; i++ {
+		clock.Set(epoch.Add(time.Duration(i) * time.Hour))
+		should.Nil(testStore.Add([]byte(`{"url":"/hello"}`)))
+		testStore.flushInputQueue()
+	}
+}
+
+func Test_clean_max_total_bytes(t *testing.T) {
+	reset()
+	should := require.New(t)
+	var testStore = newTestStore()
+	addHourlyFiles(should, testStore, 3)
+	dir := dataFiles()
+	fileSize := dir[0].Size() + testStore.indexFileSize(dir[0].Name())
+	testStore.Config.MaxTotalBytes = fileSize * 2
+	testStore.clean()
+	dir = dataFiles()
+	should.Len(dir, 2)
+	should.Equal("201701010100", dir[0].Name())
+	_, err := fs.Stat("/tmp/201701010000.idx")
+	should.NotNil(err)
+}
+
+func Test_clean_max_age(t *testing.T) {
+	reset()
+	should := require.New(t)
+	var testStore = newTestStore()
+	addHourlyFiles(should, testStore, 4)
+	testStore.Config.MaxAge = time.Hour
+	testStore.clean()
+	dir := dataFiles()
+	should.Len(dir, 2)
+	should.Equal("201701010200", dir[0].Name())
+}
+
+func Test_clean_min_free_disk_bytes(t *testing.T) {
+	reset()
+	should := require.New(t)
+	defer func(original func(string) (int64, error)) {
+		freeDiskBytes = original
+	}(freeDiskBytes)
+	freeDiskBytes = func(dir string) (int64, error) {
+		return 1, nil
+	}
+	var testStore = newTestStore()
+	addHourlyFiles(should, testStore, 3)
+	testStore.Config.MinFreeDiskBytes = 1024 * 1024
+	testStore.clean()
+	dir := dataFiles()
+	should.Len(dir, 1)
+	should.Equal("201701010200", dir[0].Name())
+}
+
+func Test_clean_keep_unrelated_files(t *testing.T) {
+	reset()
+	should := require.New(t)
+	should.Nil(vfs.WriteFile(fs, "/tmp/000-readme", []byte("hello"), 0666))
+	var testStore = newTestStore()
+	testStore.Config.KeepFilesCount = 1
+	addHourlyFiles(should, testStore, 2)
+	testStore.clean()
+	dir := dataFiles()
+	should.Len(dir, 1)
+	should.Equal("201701010100", dir[0].Name())
+	_, err := fs.Stat("/tmp/000-readme")
+	should.Nil(err)
+}
@@ -0,0 +1,225 @@
+package evtstore
+
+import (
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+	"github.com/blang/vfs"
+	"github.com/json-iterator/go"
+	"github.com/v2pro/plz/countlog"
+	"github.com/v2pro/quoll/discr"
+)
+
+const sceneStatsFileSuffix = ".scenes"
+
+// SceneStats counts the occurrences of a scene within the window starting from WindowStart.
+// Kept of them are stored in the data file, the others are dropped by the sampling of the session matcher.
+type SceneStats struct {
+	WindowStart time.Time
+	SessionType string
+	Scene       map[string]string
+	Seen        int
+	Kept        int
+}
+
+func sceneStatsFileName(fileName string) string {
+	return fileName + sceneStatsFileSuffix
+}
+
+// saveSceneStatsOf saves the counts of the discriminator for the window of the data file,
+// added to the counts saved before, such as by the late events of other flushes
+func (store *Store) saveSceneStatsOf(fileName string, discriminator discr.Discrminator) {
+	counts := sceneCountsOf(discriminator)
+	if len(counts) == 0 {
+		return
+	}
+	err := store.saveSceneStats(fileName, counts)
+	if err != nil {
+		countlog.Error("event!failed to save scene stats", "err", err, "fileName", fileName)
+	}
+}
+
+func (store *Store) saveSceneStats(fileName string, counts []discr.SceneCount) error {
+	fileTime, err := store.fileTimeOf(fileName)
+	if err != nil {
+		return err
+	}
+	saved, err := store.loadSceneStats(fileName)
+	if err != nil {
+		countlog.Error("event!failed to load scene stats, overwriting", "err", err, "fileName", fileName)
+		saved = nil
+	}
+	return store.writeSceneStats(fileName, mergeSceneStats(fileTime, saved, counts))
+}
+
+func sceneCountsOf(discriminator discr.Discrminator) []discr.SceneCount {
+	counter, ok := discriminator.(discr.SceneCounter)
+	if !ok {
+		return nil
+	}
+	return counter.SceneCounts()
+}
+
+// loadCurrentSceneStatsBase keeps the counts saved before the discriminator of current window,
+// such as by the previous process, which the counts of current window are added to
+func (store *Store) loadCurrentSceneStatsBase() {
+	fileName := store.fileNameOf(store.currentTime)
+	saved, err := store.loadSceneStats(fileName)
+	if err != nil {
+		countlog.Error("event!failed to load scene stats, overwriting", "err", err, "fileName", fileName)
+		saved = nil
+	}
+	store.currentSceneStatsBase = saved
+	store.currentSceneStatsSeen = 0
+}
+
+// saveCurrentSceneStats replaces the saved counts of current window, it is called periodically,
+// and before the discriminator of current window is replaced or the store closes
+func (store *Store) saveCurrentSceneStats() {
+	if store.currentDiscr == nil || store.currentTime.IsZero() {
+		return
+	}
+	store.lastSceneStatsTime = store.clock.Now()
+	counts := sceneCountsOf(store.currentDiscr)
+	seen := 0
+	for _, count := range counts {
+		seen += count.Seen
+	}
+	if seen == store.currentSceneStatsSeen {
+		return
+	}
+	fileName := store.fileNameOf(store.currentTime)
+	err := store.writeSceneStats(fileName, mergeSceneStats(store.currentTime, store.currentSceneStatsBase, counts))
+	if err != nil {
+		countlog.Error("event!failed to save scene stats", "err", err, "fileName", fileName)
+		return
+	}
+	store.currentSceneStatsSeen = seen
+}
+
+// saveSceneStatsIfDue saves the counts of current window every SceneStatsInterval,
+// so that a crash loses only the counts since last save, and SceneStats includes the current window
+func (store *Store) saveSceneStatsIfDue() {
+	if store.Config.SceneStatsInterval <= 0 {
+		return
+	}
+	if store.clock.Now().Sub(store.lastSceneStatsTime) < store.Config.SceneStatsInterval {
+		return
+	}
+	store.saveCurrentSceneStats()
+}
+
+// mergeSceneStats adds the counts to a copy of the saved stats
+func mergeSceneStats(windowStart time.Time, saved []SceneStats, counts []discr.SceneCount) []SceneStats {
+	stats := append([]SceneStats(nil), saved...)
+	positions := map[string]int{}
+	for i, stat := range stats {
+		positions[sceneStatsKey(stat.SessionType, stat.Scene)] = i
+	}
+	for _, count := range counts {
+		key := sceneStatsKey(count.SessionType, count.Scene)
+		if i, found := positions[key]; found {
+			stats[i].Seen += count.Seen
+			stats[i].Kept += count.Kept
+			continue
+		}
+		positions[key] = len(stats)
+		stats = append(stats, SceneStats{
+			WindowStart: windowStart,
+			SessionType: count.SessionType,
+			Scene:       count.Scene,
+			Seen:        count.Seen,
+			Kept:        count.Kept,
+		})
+	}
+	return stats
+}
+
+// writeSceneStats replaces the file through a tmp file. The rename is atomic on os filesystem,
+// while memfs can not rename over the existing file, if crashed in between loadSceneStats reads the tmp file.
+func (store *Store) writeSceneStats(fileName string, stats []SceneStats) error {
+	content, err := jsoniter.Marshal(stats)
+	if err != nil {
+		return err
+	}
+	statsFilePath := path.Join(store.RootDir, sceneStatsFileName(fileName))
+	tmpFilePath := statsFilePath + ".tmp"
+	err = vfs.WriteFile(store.fs, tmpFilePath, content, 0666)
+	if err != nil {
+		return err
+	}
+	if store.fs.Rename(tmpFilePath, statsFilePath) == nil {
+		return nil
+	}
+	err = store.fs.Remove(statsFilePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return store.fs.Rename(tmpFilePath, statsFilePath)
+}
+
+// sceneStatsKey identifies the scene regardless of the order of values
+func sceneStatsKey(sessionType string, scene map[string]string) string {
+	pairs := make([]string, 0, len(scene))
+	for key, value := range scene {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return sessionType + "\x00" + strings.Join(pairs, "\x00")
+}
+
+func (store *Store) loadSceneStats(fileName string) ([]SceneStats, error) {
+	statsFilePath := path.Join(store.RootDir, sceneStatsFileName(fileName))
+	content, err := vfs.ReadFile(store.fs, statsFilePath)
+	if os.IsNotExist(err) {
+		// the tmp file is complete if the file is removed for the rename
+		content, err = vfs.ReadFile(store.fs, statsFilePath+".tmp")
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	var stats []SceneStats
+	err = jsoniter.Unmarshal(content, &stats)
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// SceneStats lists the scene counts of the windows overlapping with the time range.
+// The counts of the window being written are as saved last, at most SceneStatsInterval ago.
+func (store *Store) SceneStats(startTime time.Time, endTime time.Time) ([]SceneStats, error) {
+	files, err := store.dataFiles()
+	if err != nil {
+		return nil, err
+	}
+	stats := []SceneStats{}
+	for i, fileInfo := range files {
+		fileName := fileInfo.Name()
+		fileTime, err := store.fileTimeOf(fileName)
+		if err != nil {
+			continue
+		}
+		// the file ends where next file starts
+		if i+1 < len(files) {
+			nextFileTime, _ := store.fileTimeOf(files[i+1].Name())
+			if !nextFileTime.After(startTime) {
+				continue
+			}
+		}
+		if fileTime.After(endTime) {
+			break
+		}
+		fileStats, err := store.loadSceneStats(fileName)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, fileStats...)
+	}
+	return stats, nil
+}
@@ -0,0 +1,151 @@
+package evtstore
+
+import (
+	"testing"
+	"github.com/stretchr/testify/require"
+	"github.com/v2pro/quoll/discr"
+	"context"
+	"time"
+)
+
+// countingDiscr keeps the first session of each body, and counts all of them
+type countingDiscr struct {
+	counts map[string]*discr.SceneCount
+}
+
+func (cd *countingDiscr) SceneOf(eventBody discr.EventBody) discr.Scene {
+	count := cd.counts[string(eventBody)]
+	if count == nil {
+		count = &discr.SceneCount{SessionType: "/hello", Scene: map[string]string{"body": string(eventBody)}}
+		cd.counts[string(eventBody)] = count
+	}
+	count.Seen++
+	if count.Seen > 1 {
+		return nil
+	}
+	count.Kept++
+	return discr.Scene{}
+}
+
+func (cd *countingDiscr) SceneCounts() []discr.SceneCount {
+	var counts []discr.SceneCount
+	for _, count := range cd.counts {
+		counts = append(counts, *count)
+	}
+	return counts
+}
+
+func useCountingDiscr() func() {
+	original := discr.NewDiscrminator
+	discr.NewDiscrminator = func() discr.Discrminator {
+		return &countingDiscr{counts: map[string]*discr.SceneCount{}}
+	}
+	return func() {
+		discr.NewDiscrminator = original
+	}
+}
+
+func Test_scene_stats_saved_at_rotation_and_close(t *testing.T) {
+	reset()
+	defer useCountingDiscr()()
+	should := require.New(t)
+	testStore := newTestStore()
+	should.Nil(testStore.Add([]byte(`a`)))
+	should.Nil(testStore.Add([]byte(`a`)))
+	should.Nil(testStore.Add([]byte(`a`)))
+	testStore.flushInputQueue()
+	stats, err := testStore.SceneStats(epoch.Add(-time.Hour), epoch.Add(time.Hour))
+	should.Nil(err)
+	should.Len(stats, 0)
+	clock.Set(epoch.Add(time.Hour))
+	should.Nil(testStore.Add([]byte(`b`)))
+	testStore.flushInputQueue()
+	should.Nil(testStore.Close(context.Background()))
+	stats, err = testStore.SceneStats(epoch.Add(-time.Hour), epoch.Add(2*time.Hour))
+	should.Nil(err)
+	should.Len(stats, 2)
+	should.Equal(map[string]string{"body": "a"}, stats[0].Scene)
+	should.Equal(3, stats[0].Seen)
+	should.Equal(1, stats[0].Kept)
+	should.Equal("201701010000", testStore.fileNameOf(stats[0].WindowStart))
+	should.Equal(map[string]string{"body": "b"}, stats[1].Scene)
+	should.Equal(1, stats[1].Seen)
+	// only the windows overlapping with the time range
+	stats, err = testStore.SceneStats(epoch.Add(time.Hour), epoch.Add(2*time.Hour))
+	should.Nil(err)
+	should.Len(stats, 1)
+}
+
+func Test_scene_stats_merged_with_saved(t *testing.T) {
+	reset()
+	defer useCountingDiscr()()
+	should := require.New(t)
+	testStore := newTestStore()
+	should.Nil(testStore.Add([]byte(`a`)))
+	should.Nil(testStore.Close(context.Background()))
+	// restarted within the same window
+	testStore = newTestStore()
+	should.Nil(testStore.Add([]byte(`a`)))
+	should.Nil(testStore.Add([]byte(`a`)))
+	should.Nil(testStore.Close(context.Background()))
+	stats, err := testStore.SceneStats(epoch.Add(-time.Hour), epoch.Add(time.Hour))
+	should.Nil(err)
+	should.Len(stats, 1)
+	should.Equal(3, stats[0].Seen)
+	should.Equal(2, stats[0].Kept)
+}
+
+func Test_scene_stats_saved_periodically(t *testing.T) {
+	reset()
+	defer useCountingDiscr()()
+	should := require.New(t)
+	testStore := newTestStore()
+	should.Nil(testStore.Add([]byte(`a`)))
+	should.Nil(testStore.Add([]byte(`a`)))
+	testStore.flushInputQueue()
+	clock.Advance(testStore.Config.SceneStatsInterval)
+	testStore.saveSceneStatsIfDue()
+	// the window being written is included
+	stats, err := testStore.SceneStats(epoch.Add(-time.Hour), epoch.Add(time.Hour))
+	should.Nil(err)
+	should.Len(stats, 1)
+	should.Equal(2, stats[0].Seen)
+	// not due yet
+	should.Nil(testStore.Add([]byte(`a`)))
+	testStore.flushInputQueue()
+	testStore.saveSceneStatsIfDue()
+	stats, err = testStore.SceneStats(epoch.Add(-time.Hour), epoch.Add(time.Hour))
+	should.Nil(err)
+	should.Equal(2, stats[0].Seen)
+	// saved again without counting the saved twice
+	clock.Advance(testStore.Config.SceneStatsInterval)
+	testStore.saveSceneStatsIfDue()
+	should.Nil(testStore.Close(context.Background()))
+	stats, err = testStore.SceneStats(epoch.Add(-time.Hour), epoch.Add(time.Hour))
+	should.Nil(err)
+	should.Len(stats, 1)
+	should.Equal(3, stats[0].Seen)
+	should.Equal(1, stats[0].Kept)
+}
+
+func Test_scene_stats_recovered_from_tmp_file(t *testing.T) {
+	reset()
+	defer useCountingDiscr()()
+	should := require.New(t)
+	testStore := newTestStore()
+	should.Nil(testStore.Add([]byte(`a`)))
+	should.Nil(testStore.Close(context.Background()))
+	// crashed after the saved file removed, before the tmp file renamed
+	statsFilePath := "/tmp/" + sceneStatsFileName(testStore.fileNameOf(testStore.windowStart(epoch)))
+	should.Nil(fs.Rename(statsFilePath, statsFilePath+".tmp"))
+	stats, err := testStore.SceneStats(epoch.Add(-time.Hour), epoch.Add(time.Hour))
+	should.Nil(err)
+	should.Len(stats, 1)
+	testStore = newTestStore()
+	should.Nil(testStore.Add([]byte(`a`)))
+	should.Nil(testStore.Close(context.Background()))
+	stats, err = testStore.SceneStats(epoch.Add(-time.Hour), epoch.Add(time.Hour))
+	should.Nil(err)
+	should.Len(stats, 1)
+	should.Equal(2, stats[0].Seen)
+}
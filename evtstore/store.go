@@ -14,29 +14,44 @@ import (
 	"github.com/v2pro/quoll/timeutil"
 	"bytes"
 	"github.com/v2pro/quoll/discr"
+	"fmt"
+	"sort"
+	"context"
+	"sync/atomic"
+	"sync"
 )
 
 const fileHeaderSize = 7
+const ctsShiftSize = 1
 const blockHeaderSize = 18
+const checksumSize = 4
+const formatVersion = 3
 const blockIdSize = 20
 const entryHeaderSize = 8
 const filenamePattern = "200601021504"
 
-var CST *time.Location
-
-func init() {
-	var err error
-	CST, err = time.LoadLocation("Asia/Shanghai")
-	if err != nil {
-		panic("timezone Asia/Shanghai not loaded: " + err.Error())
-	}
-}
-
 type Config struct {
 	BlockEntriesCountLimit uint16
 	BlockSizeLimit         int
 	MaximumFlushInterval   time.Duration
-	KeepFilesCount         int
+	// the retention policies are combined, old files are removed until all of them are satisfied.
+	// The latest file is always kept, zero to disable the policy.
+	KeepFilesCount   int
+	MaxTotalBytes    int64
+	MaxAge           time.Duration
+	MinFreeDiskBytes int64
+	// RotationInterval is the time span covered by each file, in whole minutes
+	RotationInterval time.Duration
+	// Location is the time zone to name the files and align the rotation in, UTC if nil
+	Location           *time.Location
+	InputQueueCapacity int
+	OverflowPolicy     OverflowPolicy
+	SyncPolicy         SyncPolicy
+	// SyncInterval is required by SyncPeriodically
+	SyncInterval time.Duration
+	// SceneStatsInterval is how often the scene counts of current window are saved, zero to save only
+	// on rotation and close
+	SceneStatsInterval time.Duration
 }
 
 var defaultConfig = Config{
@@ -44,6 +59,10 @@ var defaultConfig = Config{
 	BlockSizeLimit:         1024 * 1024, // byte
 	MaximumFlushInterval:   1 * time.Second,
 	KeepFilesCount:         24,
+	RotationInterval:       time.Hour,
+	InputQueueCapacity:     100,
+	OverflowPolicy:         OverflowReject,
+	SceneStatsInterval:     10 * time.Second,
 }
 
 type evtInput struct {
@@ -86,6 +105,13 @@ func (blocks EventBlocks) Next() (EventBlockId, EventBlock, EventBlocks) {
 
 type EventBlockId []byte // filename(12byte)|offset(8byte)
 
+func NewEventBlockId(fileName string, offset uint64) EventBlockId {
+	blockId := make(EventBlockId, blockIdSize)
+	copy(blockId, fileName)
+	binary.LittleEndian.PutUint64(blockId[12:], offset)
+	return blockId
+}
+
 func (blockId EventBlockId) FileName() string {
 	return string(blockId[:12])
 }
@@ -94,6 +120,8 @@ func (blockId EventBlockId) Offset() uint64 {
 	return binary.LittleEndian.Uint64(blockId[12:])
 }
 
+// EventBlock is stored in file of format version 2 with checksum(4byte) of the body after maxTimestamp,
+// the checksum is verified and stripped before the block is returned by List
 type EventBlock []byte // compressedSize(4byte)|uncompressedSize(4byte)|count(2byte)|minTimestamp(4byte)|maxTimestamp(4byte)|body
 
 func (blk EventBlock) CompressedSize() uint32 {
@@ -114,74 +142,283 @@ func (blk EventBlock) MaxCTS() uint32 {
 func (blk EventBlock) CompressedEventEntries() CompressedEventEntries {
 	return CompressedEventEntries(blk[18:])
 }
-func (blk EventBlock) EventEntries() EventEntries {
+func (blk EventBlock) Decompress() (EventEntries, error) {
 	entries := make([]byte, blk.UncompressedSize())
-	lz4.DecompressSafe(blk.CompressedEventEntries(), entries)
-	return EventEntries(entries)
+	decompressedSize := lz4.DecompressSafe(blk.CompressedEventEntries(), entries)
+	if decompressedSize != len(entries) {
+		return nil, errors.New("failed to decompress event block")
+	}
+	return EventEntries(entries), nil
+}
+
+// fileHeader of format version 1 and 2 has no ctsShift, which is always timeutil.DefaultShift
+type fileHeader []byte // magic(2byte)|version(1byte)|baseTime(4byte)|ctsShift(1byte)
+
+var errTornFileHeader = errors.New("torn file header")
+
+func newFileHeader(version byte, baseTime time.Time, ctsShift uint8) fileHeader {
+	header := fileHeader{0xD1, 0xD1, version, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint32(header[3:7], uint32(baseTime.Unix()))
+	if version > 2 {
+		header = append(header, ctsShift)
+	}
+	return header
+}
+
+func readFileHeader(file vfs.File) (fileHeader, error) {
+	header := make(fileHeader, fileHeaderSize, fileHeaderSize+ctsShiftSize)
+	_, err := file.ReadAt(header, 0)
+	if err == io.EOF {
+		return nil, errTornFileHeader
+	}
+	if err != nil {
+		return nil, err
+	}
+	if header[0] != 0xD1 || header[1] != 0xD1 {
+		return nil, errors.New("invalid magic number in file " + file.Name())
+	}
+	if header.Version() < 1 || header.Version() > formatVersion {
+		return nil, fmt.Errorf("unsupported format version %d in file %s", header.Version(), file.Name())
+	}
+	if header.Version() > 2 {
+		header = header[:fileHeaderSize+ctsShiftSize]
+		_, err = file.ReadAt(header[fileHeaderSize:], fileHeaderSize)
+		if err == io.EOF {
+			return nil, errTornFileHeader
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return header, nil
+}
+
+func (header fileHeader) Version() byte {
+	return header[2]
+}
+func (header fileHeader) BaseTime() time.Time {
+	return time.Unix(int64(binary.LittleEndian.Uint32(header[3:])), 0)
+}
+
+// CTSShift is the resolution of timestamps in the file, the file of longer rotation interval
+// has coarser resolution, e.g. 32 microseconds for daily file
+func (header fileHeader) CTSShift() uint8 {
+	if header.Version() <= 2 {
+		return timeutil.DefaultShift
+	}
+	return header[fileHeaderSize]
+}
+
+// Size is where the first block starts
+func (header fileHeader) Size() int64 {
+	return int64(len(header))
+}
+
+// BlockHeaderSize is the size of block header stored in the file, including the checksum
+func (header fileHeader) BlockHeaderSize() int64 {
+	if header.Version() == 1 {
+		return blockHeaderSize
+	}
+	return blockHeaderSize + checksumSize
+}
+
+func (header fileHeader) Compress(ts time.Time) uint32 {
+	return timeutil.CompressShifted(header.BaseTime(), ts, header.CTSShift())
+}
+
+func (header fileHeader) Decompress(cts uint32) time.Time {
+	return timeutil.DecompressShifted(header.BaseTime(), cts, header.CTSShift())
+}
+
+// canCompress tells if the timestamp fits in the file. It does not if the file is written with
+// a shorter rotation interval, or starts after the timestamp within its window.
+func (header fileHeader) canCompress(ts time.Time) bool {
+	baseTime := header.BaseTime()
+	if ts.Before(baseTime) {
+		return false
+	}
+	return ts.Sub(baseTime)>>header.CTSShift() <= math.MaxUint32
 }
 
-var fs vfs.Filesystem = vfs.OS()
+// compressBound is Compress, but clamped into the range of uint32
+func (header fileHeader) compressBound(ts time.Time) uint32 {
+	baseTime := header.BaseTime()
+	if !ts.After(baseTime) {
+		return 0
+	}
+	compressed := ts.Sub(baseTime) >> header.CTSShift()
+	if compressed > math.MaxUint32 {
+		return math.MaxUint32
+	}
+	return uint32(compressed)
+}
 
 type Store struct {
-	Config         Config
-	RootDir        string
-	inputQueue     chan evtInput
-	compressionBuf []byte
-	currentFile    vfs.File
-	currentTime    time.Time
-	currentWindow  int64
-	currentDiscr   discr.Discrminator
-}
-
-func NewStore(rootDir string) *Store {
-	return &Store{
+	// accessed atomically, kept first for 64-bit alignment
+	droppedCounters DroppedCounters
+	closed          int32
+	stopping        chan struct{}
+	stopped         chan struct{}
+	addLock         sync.RWMutex // exclusive for AddAll
+	drainedLock     sync.Mutex
+	drained         chan struct{} // closed when the input queue is drained, for the adds waiting
+	fs              vfs.Filesystem
+	clock           timeutil.Clock
+	Config          Config
+	RootDir         string
+	inputQueue      chan evtInput
+	compressionBuf  []byte
+	current         *dataFile
+	currentTime     time.Time
+	currentWindow   int64
+	currentDiscr    discr.Discrminator
+	lateInputs      []evtInput // events older than current window, saved after the queue drained
+	// currentSceneStatsBase is saved before the discriminator of current window, such as by previous process
+	currentSceneStatsBase []SceneStats
+	currentSceneStatsSeen int // saved by the discriminator of current window
+	lastSceneStatsTime    time.Time
+	dirty                 bool // saved blocks not synced yet
+	lastSyncTime          time.Time
+	repairedFiles         map[string]bool // repaired since started, the index written along is trusted later
+}
+
+type Option func(store *Store)
+
+// WithFilesystem replaces the os filesystem, such as memfs in tests
+func WithFilesystem(fs vfs.Filesystem) Option {
+	return func(store *Store) {
+		store.fs = fs
+	}
+}
+
+// WithClock replaces the system clock, such as timeutil.FakeClock in tests
+func WithClock(clock timeutil.Clock) Option {
+	return func(store *Store) {
+		store.clock = clock
+	}
+}
+
+func NewStore(rootDir string, options ...Option) *Store {
+	store := &Store{
 		Config:         defaultConfig,
 		RootDir:        rootDir,
-		inputQueue:     make(chan evtInput, 100),
+		inputQueue:     make(chan evtInput, defaultConfig.InputQueueCapacity),
 		compressionBuf: make([]byte, 1024),
+		stopping:       make(chan struct{}),
+		repairedFiles:  map[string]bool{},
+		fs:             vfs.OS(),
+		clock:          timeutil.SystemClock,
 	}
+	for _, option := range options {
+		option(store)
+	}
+	return store
 }
 
 func (store *Store) Start() error {
-	err := os.MkdirAll(store.RootDir, 0777)
+	interval := store.Config.RotationInterval
+	if interval < time.Minute || interval%time.Minute != 0 {
+		return fmt.Errorf("rotation interval %v is not in whole minutes", interval)
+	}
+	if store.Config.SyncPolicy == SyncPeriodically && store.Config.SyncInterval <= 0 {
+		return errors.New("sync interval is required to sync periodically")
+	}
+	store.resizeInputQueue()
+	err := vfs.MkdirAll(store.fs, store.RootDir, 0777)
 	if err != nil {
 		countlog.Error("event!failed to create store dir", "rootDir", store.RootDir, "err", err)
 		return err
 	}
+	err = store.migrateFileNames()
+	if err != nil {
+		countlog.Error("event!failed to migrate file names", "rootDir", store.RootDir, "err", err)
+		return err
+	}
+	err = store.repairLatestFile()
+	if err != nil {
+		countlog.Error("event!failed to repair latest file", "rootDir", store.RootDir, "err", err)
+		return err
+	}
+	store.stopped = make(chan struct{})
 	go func() {
+		defer close(store.stopped)
 		for {
 			store.flushInputQueue()
+			store.syncIfDue()
+			store.saveSceneStatsIfDue()
 			store.clean()
-			time.Sleep(store.Config.MaximumFlushInterval)
+			timer := store.clock.NewTimer(store.Config.MaximumFlushInterval)
+			select {
+			case <-store.stopping:
+				timer.Stop()
+				return
+			case <-timer.C():
+			}
 		}
 	}()
 	return nil
 }
 
-func (store *Store) clean() {
-	defer func() {
-		recovered := recover()
-		if recovered != nil {
-			countlog.Fatal("event!store.clean.panic", "err", recovered,
-				"stacktrace", countlog.ProvideStacktrace)
+// Close stops accepting Add, saves the queued events as the final block, then syncs and closes the file.
+// The store can not be started again.
+func (store *Store) Close(ctx context.Context) error {
+	if !atomic.CompareAndSwapInt32(&store.closed, 0, 1) {
+		return ErrStoreClosed
+	}
+	close(store.stopping)
+	if store.stopped != nil {
+		select {
+		case <-store.stopped:
+		case <-ctx.Done():
+			return ctx.Err()
 		}
-	}()
-	files, err := fs.ReadDir(store.RootDir)
+	}
+	// wait for the adds in progress, the later ones see closed
+	store.addLock.Lock()
+	defer store.addLock.Unlock()
+	store.flushInputQueue()
+	store.saveCurrentSceneStats()
+	err := store.closeCurrentFile(true)
+	store.saveCurrentReserved()
 	if err != nil {
-		countlog.Error("event!failed to read dir", "err", err, "rootDir", store.RootDir)
-		return
+		return err
 	}
-	if len(files) > store.Config.KeepFilesCount {
-		for _, file := range files[:len(files)-store.Config.KeepFilesCount] {
-			filePath := path.Join(store.RootDir, file.Name())
-			err := fs.Remove(filePath)
-			if err != nil {
-				countlog.Error("event!failed to clean old file", "err", err, "filePath", filePath)
-			} else {
-				countlog.Info("event!cleaned_old_file", "filePath", filePath)
-			}
+	countlog.Info("event!store.closed", "rootDir", store.RootDir)
+	return nil
+}
+
+func (store *Store) closeCurrentFile(sync bool) error {
+	if store.current == nil {
+		return nil
+	}
+	if sync && store.dirty {
+		if err := store.syncCurrentFile(); err != nil {
+			return err
 		}
 	}
+	err := store.current.close()
+	store.current = nil
+	return err
+}
+
+// dataFiles lists the files named by filenamePattern from the oldest to the latest,
+// skipping index and other files
+func (store *Store) dataFiles() ([]os.FileInfo, error) {
+	files, err := store.fs.ReadDir(store.RootDir)
+	if err != nil {
+		return nil, err
+	}
+	dataFiles := files[:0]
+	for _, file := range files {
+		if !isDataFileName(file.Name()) {
+			continue
+		}
+		dataFiles = append(dataFiles, file)
+	}
+	sort.Slice(dataFiles, func(i, j int) bool {
+		return dataFiles[i].Name() < dataFiles[j].Name()
+	})
+	return dataFiles, nil
 }
 
 func (store *Store) flushInputQueue() {
@@ -199,250 +436,554 @@ func (store *Store) flushInputQueue() {
 				"stacktrace", countlog.ProvideStacktrace)
 		}
 	}()
-	tmpBuf := [4]byte{}
-	blockBody := []byte{}
+	builder := &blockBuilder{}
 	for {
-		shouldContinue, entriesCount := store.flushOnce(tmpBuf, blockBody)
+		shouldContinue, entriesCount := store.flushOnce(builder)
+		store.notifyQueueDrained()
 		if !shouldContinue {
 			break
 		}
 		totalEntriesCount += int(entriesCount)
 	}
+	totalEntriesCount += store.flushLateInputs(builder)
 }
 
-func (store *Store) flushOnce(tmpBuf [4]byte, blockBody []byte) (bool, uint16) {
-	entriesCount := uint16(0)
-	blockBody = blockBody[:0]
-	minCTS := uint32(math.MaxUint32)
-	maxCTS := uint32(0)
+func (store *Store) flushOnce(builder *blockBuilder) (bool, uint16) {
+	builder.reset()
 	for {
 		select {
 		case input := <-store.inputQueue:
 			startProcessInputTime := time.Now()
-			if input.eventTS.Sub(store.currentTime) > time.Hour && len(blockBody) > 0 {
-				err := store.saveBlock(entriesCount, minCTS, maxCTS, blockBody)
+			window := store.windowOf(input.eventTS)
+			if window < store.currentWindow {
+				// the current file is kept open, late events go to their own files later
+				store.lateInputs = append(store.lateInputs, input)
+				continue
+			}
+			if window != store.currentWindow && builder.entriesCount > 0 {
+				err := store.saveBlock(builder)
 				if err != nil {
 					countlog.Error("event!failed to save block", "err", err)
-					return false, entriesCount
+					return false, builder.entriesCount
 				}
-				entriesCount = uint16(0)
-				blockBody = blockBody[:0]
-				minCTS = uint32(math.MaxUint32)
-				maxCTS = uint32(0)
+				builder.reset()
 			}
 			if err := store.switchFile(input.eventTS); err != nil {
 				countlog.Error("event!failed to switch file", "err", err)
-				return false, entriesCount
+				return false, builder.entriesCount
 			}
-			scene := store.currentDiscr.SceneOf(input.eventBody)
+			scene := sceneOf(store.currentDiscr, input)
 			if scene == nil {
 				continue
 			}
-			eventCTS := timeutil.Compress(store.currentTime, input.eventTS)
-			if eventCTS > maxCTS {
-				maxCTS = eventCTS
-			}
-			if eventCTS < minCTS {
-				minCTS = eventCTS
+			if !store.current.header.canCompress(input.eventTS) {
+				if builder.entriesCount > 0 {
+					err := store.saveBlock(builder)
+					if err != nil {
+						countlog.Error("event!failed to save block", "err", err)
+						return false, builder.entriesCount
+					}
+					builder.reset()
+				}
+				if err := store.rollFile(input.eventTS); err != nil {
+					countlog.Error("event!failed to roll file", "err", err)
+					return false, builder.entriesCount
+				}
 			}
-			entriesCount++
-			binary.LittleEndian.PutUint32(tmpBuf[:], uint32(len(input.eventBody)))
-			blockBody = append(blockBody, tmpBuf[:]...)
-			binary.LittleEndian.PutUint32(tmpBuf[:], eventCTS)
-			blockBody = append(blockBody, tmpBuf[:]...)
-			blockBody = append(blockBody, input.eventBody...)
+			builder.add(store.current.header.Compress(input.eventTS), input.eventBody)
 			countlog.Trace("event!store.added_event", "latency", time.Since(startProcessInputTime))
-			if entriesCount > store.Config.BlockEntriesCountLimit {
-				break
-			}
-			if len(blockBody) > store.Config.BlockSizeLimit {
+			if builder.isFull(&store.Config) {
 				break
 			}
 			continue
 		default:
-			if len(blockBody) > 0 {
+			if builder.entriesCount > 0 {
 				break
 			}
-			return false, entriesCount
+			return false, builder.entriesCount
 		}
 		break
 	}
-	err := store.saveBlock(entriesCount, minCTS, maxCTS, blockBody)
+	err := store.saveBlock(builder)
 	if err != nil {
 		countlog.Error("event!failed to save block", "err", err)
-		return false, entriesCount
+		return false, builder.entriesCount
 	}
-	return true, entriesCount
+	return true, builder.entriesCount
 }
 
-func (store *Store) saveBlock(entriesCount uint16, minCTS, maxCTS uint32, blockBody []byte) error {
-	file := store.currentFile
-	var blockHeader [blockHeaderSize]byte
-	bound := lz4.CompressBound(len(blockBody))
-	if len(store.compressionBuf) < bound {
-		store.compressionBuf = make([]byte, bound)
+// flushLateInputs saves the events older than the current window into the files of their windows.
+// The discriminator of past window is gone, so late events are only deduplicated among themselves.
+func (store *Store) flushLateInputs(builder *blockBuilder) int {
+	inputs := store.lateInputs
+	store.lateInputs = nil
+	sort.SliceStable(inputs, func(i, j int) bool {
+		return store.windowOf(inputs[i].eventTS) < store.windowOf(inputs[j].eventTS)
+	})
+	savedCount := 0
+	for len(inputs) > 0 {
+		window := store.windowOf(inputs[0].eventTS)
+		end := 1
+		for end < len(inputs) && store.windowOf(inputs[end].eventTS) == window {
+			end++
+		}
+		entriesCount, err := store.saveLateInputs(builder, inputs[:end])
+		if err != nil {
+			countlog.Error("event!failed to save late events", "err", err,
+				"count", end, "savedCount", entriesCount)
+		}
+		savedCount += entriesCount
+		inputs = inputs[end:]
 	}
-	compressedSize := lz4.CompressDefault(blockBody, store.compressionBuf)
-	binary.LittleEndian.PutUint32(blockHeader[0:4], uint32(compressedSize))
-	binary.LittleEndian.PutUint32(blockHeader[4:8], uint32(len(blockBody)))
-	binary.LittleEndian.PutUint16(blockHeader[8:10], uint16(entriesCount))
-	binary.LittleEndian.PutUint32(blockHeader[10:14], minCTS)
-	binary.LittleEndian.PutUint32(blockHeader[14:blockHeaderSize], maxCTS)
-	_, err := file.Write(blockHeader[:])
+	return savedCount
+}
+
+func (store *Store) saveLateInputs(builder *blockBuilder, inputs []evtInput) (int, error) {
+	storable := inputs[:0]
+	for _, input := range inputs {
+		if store.canStore(input.eventTS) {
+			storable = append(storable, input)
+			continue
+		}
+		countlog.Error("event!store.drop_unstorable_event", "eventTS", input.eventTS)
+	}
+	inputs = storable
+	if len(inputs) == 0 {
+		return 0, nil
+	}
+	discriminator := discr.NewDiscrminator()
+	sampled := make([]evtInput, 0, len(inputs))
+	for _, input := range inputs {
+		if sceneOf(discriminator, input) != nil {
+			sampled = append(sampled, input)
+		}
+	}
+	// the reservoirs are complete, as the discriminator only sees this batch
+	sampled = append(sampled, reservedInputs(discriminator)...)
+	savedCount, err := store.saveInputs(builder, inputs[0].eventTS, sampled)
 	if err != nil {
-		return err
+		return savedCount, err
+	}
+	store.saveSceneStatsOf(store.fileNameOf(store.windowStart(inputs[0].eventTS)), discriminator)
+	return savedCount, nil
+}
+
+// saveCurrentReserved writes the sessions kept by the reservoirs of the current window,
+// once the window ends and the current file is closed
+func (store *Store) saveCurrentReserved() {
+	if store.currentDiscr == nil {
+		return
+	}
+	inputs := reservedInputs(store.currentDiscr)
+	if len(inputs) == 0 {
+		return
+	}
+	savedCount, err := store.saveInputs(&blockBuilder{}, store.currentTime, inputs)
+	if err != nil {
+		countlog.Error("event!failed to save reserved events", "err", err,
+			"count", len(inputs), "savedCount", savedCount)
+	}
+}
+
+// reservedInputs takes the sessions kept by the reservoir sampling of the discriminator, in the order of time
+func reservedInputs(discriminator discr.Discrminator) []evtInput {
+	reservoir, ok := discriminator.(discr.Reservoir)
+	if !ok {
+		return nil
+	}
+	reserved := reservoir.TakeReserved()
+	inputs := make([]evtInput, len(reserved))
+	for i, session := range reserved {
+		inputs[i] = evtInput{eventTS: session.EventTS, eventBody: session.EventBody}
+	}
+	sort.Slice(inputs, func(i, j int) bool {
+		return inputs[i].eventTS.Before(inputs[j].eventTS)
+	})
+	return inputs
+}
+
+// saveInputs writes the sampled events of one window, starting from the file for ts
+func (store *Store) saveInputs(builder *blockBuilder, ts time.Time, inputs []evtInput) (int, error) {
+	target, err := store.openDataFileFor(ts)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if target == nil {
+			return
+		}
+		if err := target.close(); err != nil {
+			countlog.Error("event!failed to close file", "err", err, "fileName", target.file.Name())
+		}
+	}()
+	savedCount := 0
+	builder.reset()
+	for _, input := range inputs {
+		if !target.header.canCompress(input.eventTS) {
+			if builder.entriesCount > 0 {
+				if err := store.writeBlock(target, builder); err != nil {
+					return savedCount, err
+				}
+				savedCount += int(builder.entriesCount)
+				builder.reset()
+			}
+			if store.Config.SyncPolicy != SyncNever {
+				if err := target.file.Sync(); err != nil {
+					return savedCount, err
+				}
+			}
+			if err := target.close(); err != nil {
+				countlog.Error("event!failed to close file", "err", err, "fileName", target.file.Name())
+			}
+			// the deferred close is for the reopened target
+			target, err = store.openDataFileFor(input.eventTS)
+			if err != nil {
+				target = nil
+				return savedCount, err
+			}
+		}
+		builder.add(target.header.Compress(input.eventTS), input.eventBody)
+		if builder.isFull(&store.Config) {
+			if err := store.writeBlock(target, builder); err != nil {
+				return savedCount, err
+			}
+			savedCount += int(builder.entriesCount)
+			builder.reset()
+		}
+	}
+	if builder.entriesCount > 0 {
+		if err := store.writeBlock(target, builder); err != nil {
+			return savedCount, err
+		}
+		savedCount += int(builder.entriesCount)
+	}
+	if store.Config.SyncPolicy != SyncNever {
+		if err := target.file.Sync(); err != nil {
+			return savedCount, err
+		}
+	}
+	countlog.Debug("event!store.saved_events", "fileName", target.file.Name(),
+		"count", len(inputs))
+	return savedCount, nil
+}
+
+// blockBuilder accumulates the event entries of next block
+type blockBuilder struct {
+	body         []byte
+	entriesCount uint16
+	minCTS       uint32
+	maxCTS       uint32
+}
+
+func (builder *blockBuilder) reset() {
+	builder.body = builder.body[:0]
+	builder.entriesCount = 0
+	builder.minCTS = uint32(math.MaxUint32)
+	builder.maxCTS = 0
+}
+
+func (builder *blockBuilder) add(eventCTS uint32, eventBody discr.EventBody) {
+	if eventCTS > builder.maxCTS {
+		builder.maxCTS = eventCTS
 	}
-	_, err = file.Write(store.compressionBuf[:compressedSize])
+	if eventCTS < builder.minCTS {
+		builder.minCTS = eventCTS
+	}
+	builder.entriesCount++
+	var tmpBuf [4]byte
+	binary.LittleEndian.PutUint32(tmpBuf[:], uint32(len(eventBody)))
+	builder.body = append(builder.body, tmpBuf[:]...)
+	binary.LittleEndian.PutUint32(tmpBuf[:], eventCTS)
+	builder.body = append(builder.body, tmpBuf[:]...)
+	builder.body = append(builder.body, eventBody...)
+}
+
+func (builder *blockBuilder) isFull(config *Config) bool {
+	return builder.entriesCount > config.BlockEntriesCountLimit || len(builder.body) > config.BlockSizeLimit
+}
+
+func (store *Store) saveBlock(builder *blockBuilder) error {
+	err := store.writeBlock(store.current, builder)
+	store.dirty = true
 	if err != nil {
 		return err
 	}
+	store.syncAfterBlock()
 	return nil
 }
 
+func (store *Store) writeBlock(target *dataFile, builder *blockBuilder) error {
+	var blockHeader [blockHeaderSize + checksumSize]byte
+	bound := lz4.CompressBound(len(builder.body))
+	if len(store.compressionBuf) < bound {
+		store.compressionBuf = make([]byte, bound)
+	}
+	compressedSize := lz4.CompressDefault(builder.body, store.compressionBuf)
+	binary.LittleEndian.PutUint32(blockHeader[0:4], uint32(compressedSize))
+	binary.LittleEndian.PutUint32(blockHeader[4:8], uint32(len(builder.body)))
+	binary.LittleEndian.PutUint16(blockHeader[8:10], builder.entriesCount)
+	binary.LittleEndian.PutUint32(blockHeader[10:14], builder.minCTS)
+	binary.LittleEndian.PutUint32(blockHeader[14:blockHeaderSize], builder.maxCTS)
+	binary.LittleEndian.PutUint32(blockHeader[blockHeaderSize:], checksumOf(store.compressionBuf[:compressedSize]))
+	return target.appendBlock(blockHeader[:], store.compressionBuf[:compressedSize])
+}
+
+func isDataFileName(fileName string) bool {
+	_, err := time.Parse(filenamePattern, fileName)
+	return err == nil
+}
+
+func (store *Store) location() *time.Location {
+	if store.Config.Location == nil {
+		return time.UTC
+	}
+	return store.Config.Location
+}
+
+func (store *Store) fileNameOf(fileTime time.Time) string {
+	return fileTime.In(store.location()).Format(filenamePattern)
+}
+
+func (store *Store) fileTimeOf(fileName string) (time.Time, error) {
+	return time.ParseInLocation(filenamePattern, fileName, store.location())
+}
+
+// windowOf numbers the rotation interval the timestamp falls in,
+// the windows are aligned to the local time so that daily files start from midnight
+func (store *Store) windowOf(ts time.Time) int64 {
+	_, zoneOffset := ts.In(store.location()).Zone()
+	return (ts.Unix() + int64(zoneOffset)) / int64(store.Config.RotationInterval/time.Second)
+}
+
+// windowStart is the start time of the window the timestamp falls in, which names the file
+func (store *Store) windowStart(ts time.Time) time.Time {
+	_, zoneOffset := ts.In(store.location()).Zone()
+	return time.Unix(store.windowOf(ts)*int64(store.Config.RotationInterval/time.Second)-int64(zoneOffset), 0)
+}
+
+// sceneOf samples by the event timestamp if the discriminator supports
+func sceneOf(discriminator discr.Discrminator, input evtInput) discr.Scene {
+	if timed, ok := discriminator.(discr.TimedDiscrminator); ok {
+		return timed.SceneOfAt(input.eventTS, input.eventBody)
+	}
+	return discriminator.SceneOf(input.eventBody)
+}
+
 func (store *Store) switchFile(ts time.Time) error {
-	window := ts.Unix() / 3600
+	window := store.windowOf(ts)
 	if window == store.currentWindow {
 		return nil
 	}
+	store.saveCurrentSceneStats()
+	if err := store.closeCurrentFile(store.Config.SyncPolicy != SyncNever); err != nil {
+		return err
+	}
+	store.saveCurrentReserved()
 	store.currentDiscr = discr.NewDiscrminator()
-	if store.currentFile != nil {
-		if err := store.currentFile.Close(); err != nil {
-			return err
-		}
+	current, err := store.openDataFileFor(ts)
+	if err != nil {
+		return err
 	}
+	store.current = current
+	store.currentTime = store.windowStart(ts)
 	store.currentWindow = window
-	store.currentTime = time.Unix(window*3600, 0)
-	fileName := store.currentTime.Format(filenamePattern)
-	file, err := fs.OpenFile(
-		path.Join(store.RootDir, fileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	store.loadCurrentSceneStatsBase()
+	return nil
+}
+
+// rollFile continues the current window in another file, as the current one can not hold the timestamp
+func (store *Store) rollFile(ts time.Time) error {
+	if err := store.closeCurrentFile(store.Config.SyncPolicy != SyncNever); err != nil {
+		return err
+	}
+	current, err := store.openDataFileFor(ts)
 	if err != nil {
-		file, err = fs.OpenFile(
-			path.Join(store.RootDir, fileName), os.O_WRONLY|os.O_APPEND, 0666)
-		if err != nil {
-			return err
-		}
-	} else {
-		header := [fileHeaderSize]byte{0xD1, 0xD1, 1, 0, 0, 0, 0}
-		binary.LittleEndian.PutUint32(header[3:7], uint32(store.currentTime.Unix()))
-		_, err = file.Write(header[:])
-		if err != nil {
-			return err
-		}
+		return err
 	}
-	file.Seek(0, io.SeekEnd)
-	store.currentFile = file
+	store.current = current
 	return nil
 }
 
-func (store *Store) Add(eventBody discr.EventBody) error {
-	select {
-	case store.inputQueue <- evtInput{
-		eventBody: eventBody,
-		eventTS:   timeutil.Now(),
-	}:
-		return nil
-	default:
-		return errors.New("input queue overflow")
+func (store *Store) List(startTime time.Time, endTime time.Time, skip int, limit int) (EventBlocks, error) {
+	blocks, _, err := store.ListAfter(nil, startTime, endTime, skip, limit)
+	return blocks, err
+}
+
+// ListAfter continues the listing from the cursor returned by previous page, nil cursor to start
+// from startTime. The returned cursor is positioned after the last returned block, so new blocks
+// saved in between do not shift the pages. The block partially returned by QueryAfter is
+// returned again as a whole.
+func (store *Store) ListAfter(cursor Cursor, startTime time.Time, endTime time.Time,
+	skip int, limit int) (EventBlocks, Cursor, error) {
+	collector := &blocksCollector{
+		cursor:      cursor,
+		skip:        skip,
+		limit:       limit,
+		eventBlocks: bytes.NewBuffer(nil),
+	}
+	err := store.walkBlocks(startTime, endTime, cursor, collector)
+	if err != nil {
+		return nil, nil, err
+	}
+	nextCursor := cursor
+	if collector.lastBlockId != nil {
+		nextCursor = NewCursor(collector.lastBlockId, uint32(collector.lastEntriesCount))
 	}
+	return EventBlocks(collector.eventBlocks.Bytes()), nextCursor, nil
 }
 
-func (store *Store) List(startTime time.Time, endTime time.Time, skip int, limit int) (EventBlocks, error) {
-	files, err := fs.ReadDir(store.RootDir)
+type blocksCollector struct {
+	cursor           Cursor
+	skip             int
+	limit            int
+	readEntriesCount int
+	eventBlocks      *bytes.Buffer
+	lastBlockId      EventBlockId
+	lastEntriesCount uint16
+}
+
+func (collector *blocksCollector) skipBlock(blockId EventBlockId, header fileHeader, entry blockIndexEntry) bool {
+	if collector.cursor.consumed(blockId, entry) {
+		return true
+	}
+	if collector.readEntriesCount < collector.skip {
+		collector.readEntriesCount += int(entry.EntriesCount())
+		return true
+	}
+	return false
+}
+
+func (collector *blocksCollector) visitBlock(blockId EventBlockId, block EventBlock, header fileHeader) (bool, error) {
+	_, err := collector.eventBlocks.Write(blockId)
 	if err != nil {
-		return nil, err
+		return false, err
+	}
+	_, err = collector.eventBlocks.Write(block)
+	if err != nil {
+		return false, err
 	}
-	eventBlocks := bytes.NewBuffer(nil)
-	var headerBuf = [blockHeaderSize]byte{}
-	var header EventBlock = headerBuf[:]
-	var fileHeader = [4]byte{}
-	readEntriesCount := 0
-	var copyBuf = [4096]byte{}
-	for _, fileInfo := range files {
+	collector.lastBlockId = blockId
+	collector.lastEntriesCount = block.EntriesCount()
+	collector.readEntriesCount += int(block.EntriesCount())
+	return collector.readEntriesCount > collector.skip+collector.limit, nil
+}
+
+// blockVisitor receives the blocks overlapping with the time range, one file after another
+type blockVisitor interface {
+	// skipBlock tells if the block can be skipped without reading it
+	skipBlock(blockId EventBlockId, header fileHeader, entry blockIndexEntry) bool
+	// visitBlock returns true to stop walking, the block is reused after the call returns
+	visitBlock(blockId EventBlockId, block EventBlock, header fileHeader) (bool, error)
+}
+
+// walkBlocks visits the blocks from the one pointed by cursor, or from startTime if cursor is nil
+func (store *Store) walkBlocks(startTime time.Time, endTime time.Time, cursor Cursor, visitor blockVisitor) error {
+	files, err := store.dataFiles()
+	if err != nil {
+		return err
+	}
+	for i, fileInfo := range files {
 		filename := fileInfo.Name()
-		fileTime, err := time.ParseInLocation(filenamePattern, filename, CST)
-		if err != nil {
+		if cursor != nil && filename < cursor.BlockId().FileName() {
 			continue
 		}
-		if fileTime.Add(time.Hour).Before(startTime) {
-			countlog.Debug("event!skip_file_because_time_too_small",
-				"fileTime", fileTime, "startTime", startTime)
+		fileTime, err := store.fileTimeOf(filename)
+		if err != nil {
 			continue
 		}
+		// the file is not written any more, unless by late events.
+		// The name of next file does not tell where the file ends, as the late file of an older window
+		// can be named before the events of a longer window end, the file is skipped by its index instead.
+		sealed := i+1 < len(files) || !fileTime.Add(store.Config.RotationInterval).After(store.clock.Now())
 		if fileTime.After(endTime) {
 			countlog.Debug("event!skip_file_because_time_too_large",
 				"fileTime", fileTime, "endTime", endTime)
 			continue
 		}
-		blockIdTmpl := []byte(filename)
-		blockIdTmpl = append(blockIdTmpl, []byte{0, 0, 0, 0, 0, 0, 0, 0}...)
-		file, err := fs.OpenFile(path.Join(store.RootDir, filename), os.O_RDONLY, 0)
+		stopped, err := store.walkFile(filename, sealed, startTime, endTime, cursor, visitor)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		file.Seek(3, io.SeekStart)
-		_, err = io.ReadFull(file, fileHeader[:])
-		if err != nil {
-			return nil, err
-		}
-		baseTime := time.Unix(int64(binary.LittleEndian.Uint32(fileHeader[:])), 0)
-		for {
-			_, err = io.ReadFull(file, header)
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				return nil, err
-			}
-			shouldSkip := readEntriesCount < skip
-			if shouldSkip {
-				readEntriesCount += int(header.EntriesCount())
-			}
-			minTime := timeutil.Decompress(baseTime, header.MinCTS())
-			if minTime.After(endTime) {
-				shouldSkip = true
-			}
-			maxTime := timeutil.Decompress(baseTime, header.MaxCTS())
-			if maxTime.Before(startTime) {
-				shouldSkip = true
-			}
-			if shouldSkip {
-				file.Seek(int64(header.CompressedSize()), io.SeekCurrent)
-				continue
-			}
-			offset, err := file.Seek(0, io.SeekCurrent)
-			if err != nil {
-				return nil, err
-			}
-			binary.LittleEndian.PutUint64(blockIdTmpl[12:], uint64(offset))
-			_, err = eventBlocks.Write(blockIdTmpl)
-			if err != nil {
-				return nil, err
-			}
-			_, err = eventBlocks.Write(header)
-			if err != nil {
-				return nil, err
-			}
-			_, err = copyN(eventBlocks, file, int64(header.CompressedSize()), copyBuf[:])
-			if err != nil {
-				return nil, err
-			}
-			readEntriesCount += int(header.EntriesCount())
-			if readEntriesCount > skip+limit {
-				return EventBlocks(eventBlocks.Bytes()), nil
-			}
+		if stopped {
+			return nil
 		}
 	}
-	return EventBlocks(eventBlocks.Bytes()), nil
+	return nil
 }
 
-func copyN(dst io.Writer, src io.Reader, n int64, buf []byte) (written int64, err error) {
-	written, err = io.CopyBuffer(dst, io.LimitReader(src, n), buf)
-	if written == n {
-		return n, nil
+func (store *Store) walkFile(filename string, sealed bool,
+	startTime time.Time, endTime time.Time, cursor Cursor, visitor blockVisitor) (bool, error) {
+	file, err := store.fs.OpenFile(path.Join(store.RootDir, filename), os.O_RDONLY, 0)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	fileHeader, err := readFileHeader(file)
+	if err != nil {
+		return false, err
+	}
+	index, rebuilt, err := store.loadIndex(filename, file, fileHeader)
+	if err != nil {
+		return false, err
+	}
+	if rebuilt && sealed {
+		// keep the rebuilt index for next time
+		if err := store.saveIndex(filename, index); err != nil {
+			countlog.Error("event!failed to save rebuilt index", "err", err, "filename", filename)
+		}
+	}
+	headerSize := fileHeader.BlockHeaderSize()
+	var blockBuf []byte
+	// the ceil of the last block is the max timestamp of the file
+	first := index.search(fileHeader.compressBound(startTime))
+	if first == index.Len() {
+		countlog.Debug("event!skip_file_because_time_too_small",
+			"filename", filename, "startTime", startTime)
+		return false, nil
 	}
-	if written < n && err == nil {
-		// src stopped early; must have been EOF.
-		err = io.EOF
+	if cursor != nil && filename == cursor.BlockId().FileName() {
+		pos, found := index.find(int64(cursor.BlockId().Offset()) - headerSize)
+		if !found {
+			return false, fmt.Errorf("cursor offset %d is not at block boundary", cursor.BlockId().Offset())
+		}
+		if pos > first {
+			first = pos
+		}
+	}
+	for i := first; i < index.Len(); i++ {
+		entry := index.Entry(i)
+		minTime := fileHeader.Decompress(entry.MinCTS())
+		if minTime.After(endTime) {
+			continue
+		}
+		maxTime := fileHeader.Decompress(entry.MaxCTS())
+		if maxTime.Before(startTime) {
+			continue
+		}
+		blockId := NewEventBlockId(filename, uint64(entry.Offset()+headerSize))
+		if visitor.skipBlock(blockId, fileHeader, entry) {
+			continue
+		}
+		storedSize := int(headerSize) + int(entry.CompressedSize())
+		if cap(blockBuf) < storedSize {
+			blockBuf = make([]byte, storedSize)
+		}
+		block, err := readBlock(file, fileHeader, entry, blockBuf[:storedSize])
+		if err == errCorruptedBlock {
+			countlog.Error("event!store.skipped_corrupted_block",
+				"filename", filename, "offset", entry.Offset())
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		stopped, err := visitor.visitBlock(blockId, block, fileHeader)
+		if err != nil {
+			return false, err
+		}
+		if stopped {
+			return true, nil
+		}
 	}
-	return
+	return false, nil
 }
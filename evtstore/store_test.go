@@ -7,11 +7,20 @@ import (
 	"time"
 	"github.com/v2pro/quoll/timeutil"
 	"github.com/v2pro/quoll/discr"
+	"os"
+	"context"
+	"github.com/blang/vfs"
+	"sort"
 )
 
+// the tests share the filesystem and clock replaced by reset, so they can not run in parallel
+var epoch = time.Unix(1483228900, 0)
+
+var fs vfs.Filesystem
+
+var clock *timeutil.FakeClock
+
 func init() {
-	epoch := time.Unix(1483228900, 0)
-	timeutil.MockNow(epoch)
 	discr.NewDiscrminator = func() discr.Discrminator {
 		return &mockDiscr{}
 	}
@@ -27,95 +36,452 @@ func (md *mockDiscr) SceneOf(eventBody discr.EventBody) discr.Scene {
 func reset() {
 	fs = memfs.Create()
 	fs.Mkdir("/tmp", 0666)
+	clock = timeutil.NewFakeClock(epoch)
+}
+
+func newTestStore() *Store {
+	return NewStore("/tmp", WithFilesystem(fs), WithClock(clock))
+}
+
+func dataFiles() []os.FileInfo {
+	files, _ := newTestStore().dataFiles()
+	return files
 }
 
 func Test_add_one(t *testing.T) {
 	reset()
 	should := require.New(t)
-	var testStore = NewStore("/tmp")
+	var testStore = newTestStore()
 	err := testStore.Add([]byte(`{"url":"/hello"}`))
 	should.Nil(err)
 	testStore.flushInputQueue()
-	dir, _ := fs.ReadDir("/tmp")
+	dir := dataFiles()
 	should.Len(dir, 1)
-	should.Equal("201701010800", dir[0].Name())
+	should.Equal("201701010000", dir[0].Name())
 }
 
 func Test_add_multiple(t *testing.T) {
 	reset()
 	should := require.New(t)
-	var testStore = NewStore("/tmp")
+	var testStore = newTestStore()
 	err := testStore.Add([]byte(`{"url":"/hello"}`))
 	should.Nil(err)
 	err = testStore.Add([]byte(`{"url":"/hello"}`))
 	should.Nil(err)
 	testStore.flushInputQueue()
-	dir, _ := fs.ReadDir("/tmp")
+	dir := dataFiles()
 	should.Len(dir, 1)
-	should.Equal("201701010800", dir[0].Name())
+	should.Equal("201701010000", dir[0].Name())
 }
 
 func Test_rotation_happen_between_flush(t *testing.T) {
 	reset()
 	should := require.New(t)
-	var testStore = NewStore("/tmp")
+	var testStore = newTestStore()
 	should.Nil(testStore.Add([]byte(`{"url":"/hello"}`)))
 	testStore.flushInputQueue()
-	timeutil.MockNow(timeutil.Now().Add(time.Hour))
+	clock.Set(clock.Now().Add(time.Hour))
 	should.Nil(testStore.Add([]byte(`{"url":"/hello"}`)))
 	testStore.flushInputQueue()
-	dir, _ := fs.ReadDir("/tmp")
+	dir := dataFiles()
 	should.Len(dir, 2)
-	should.Equal("201701010800", dir[0].Name())
-	should.Equal("201701010900", dir[1].Name())
+	should.Equal("201701010000", dir[0].Name())
+	should.Equal("201701010100", dir[1].Name())
 }
 
 func Test_rotation_happen_within_flush(t *testing.T) {
 	reset()
 	should := require.New(t)
-	var testStore = NewStore("/tmp")
+	var testStore = newTestStore()
 	should.Nil(testStore.Add([]byte(`{"url":"/hello"}`)))
-	timeutil.MockNow(timeutil.Now().Add(time.Hour))
+	clock.Set(clock.Now().Add(time.Hour))
 	should.Nil(testStore.Add([]byte(`{"url":"/hello"}`)))
 	testStore.flushInputQueue()
-	dir, _ := fs.ReadDir("/tmp")
+	dir := dataFiles()
 	should.Len(dir, 2)
-	should.Equal("201701010800", dir[0].Name())
+	should.Equal("201701010000", dir[0].Name())
 	should.True(dir[0].Size() > 0)
-	should.Equal("201701010900", dir[1].Name())
+	should.Equal("201701010100", dir[1].Name())
 	should.True(dir[1].Size() > 0)
 }
 
+func Test_rotation_interval(t *testing.T) {
+	reset()
+	should := require.New(t)
+	var testStore = newTestStore()
+	testStore.Config.RotationInterval = 24 * time.Hour
+	should.Nil(testStore.Add([]byte(`{"url":"/hello1"}`)))
+	testStore.flushInputQueue()
+	clock.Set(epoch.Add(10 * time.Hour))
+	should.Nil(testStore.Add([]byte(`{"url":"/hello2"}`)))
+	testStore.flushInputQueue()
+	dir := dataFiles()
+	should.Len(dir, 1)
+	should.Equal("201701010000", dir[0].Name())
+	// the hourly files written after reconfiguration are read together with the daily file
+	testStore = newTestStore()
+	clock.Set(epoch.Add(20 * time.Hour))
+	should.Nil(testStore.Add([]byte(`{"url":"/hello3"}`)))
+	testStore.flushInputQueue()
+	dir = dataFiles()
+	should.Len(dir, 2)
+	should.Equal("201701012000", dir[1].Name())
+	// the timestamps in daily file are truncated to 32 microseconds
+	iter, err := testStore.Query(epoch.Add(-time.Second), epoch.Add(24*time.Hour), 0, 10)
+	should.Nil(err)
+	event := iter.Next()
+	should.Equal(`{"url":"/hello1"}`, string(event.Body))
+	should.True(epoch.Sub(event.Timestamp) < time.Millisecond)
+	event = iter.Next()
+	should.Equal(`{"url":"/hello2"}`, string(event.Body))
+	should.True(epoch.Add(10*time.Hour).Sub(event.Timestamp) < time.Millisecond)
+	event = iter.Next()
+	should.Equal(`{"url":"/hello3"}`, string(event.Body))
+	should.Equal(epoch.Add(20*time.Hour), event.Timestamp)
+	should.False(iter.HasNext())
+}
+
+func Test_rotation_interval_hourly_to_daily(t *testing.T) {
+	reset()
+	should := require.New(t)
+	var testStore = newTestStore()
+	should.Nil(testStore.Add([]byte(`{"url":"/hello1"}`)))
+	testStore.flushInputQueue()
+	// the hourly file of midnight has the name of daily file, but can not hold the whole day
+	testStore = newTestStore()
+	testStore.Config.RotationInterval = 24 * time.Hour
+	clock.Set(epoch.Add(10 * time.Hour))
+	should.Nil(testStore.Add([]byte(`{"url":"/hello3"}`)))
+	should.Nil(testStore.AddAt(epoch.Add(5*time.Hour), []byte(`{"url":"/hello2"}`)))
+	testStore.flushInputQueue()
+	clock.Set(epoch.Add(20 * time.Hour))
+	should.Nil(testStore.Add([]byte(`{"url":"/hello4"}`)))
+	testStore.flushInputQueue()
+	dir := dataFiles()
+	should.Len(dir, 3)
+	should.Equal("201701010000", dir[0].Name())
+	should.Equal("201701010501", dir[1].Name())
+	should.Equal("201701011001", dir[2].Name())
+	iter, err := testStore.Query(epoch.Add(-time.Second), epoch.Add(24*time.Hour), 0, 10)
+	should.Nil(err)
+	bodies := []string{}
+	for iter.HasNext() {
+		bodies = append(bodies, string(iter.Next().Body))
+	}
+	// the window continues in the file opened last, so the events are not ordered across files
+	sort.Strings(bodies)
+	should.Equal([]string{`{"url":"/hello1"}`, `{"url":"/hello2"}`, `{"url":"/hello3"}`, `{"url":"/hello4"}`}, bodies)
+	corrupted, err := testStore.Verify()
+	should.Nil(err)
+	should.Len(corrupted, 0)
+}
+
+func Test_rotation_interval_daily_to_hourly_with_late_file(t *testing.T) {
+	reset()
+	should := require.New(t)
+	var testStore = newTestStore()
+	testStore.Config.RotationInterval = 24 * time.Hour
+	should.Nil(testStore.Add([]byte(`{"url":"/hello1"}`)))
+	clock.Set(epoch.Add(4 * time.Hour))
+	should.Nil(testStore.Add([]byte(`{"url":"/hello2"}`)))
+	testStore.flushInputQueue()
+	testStore = newTestStore()
+	clock.Set(epoch.Add(5 * time.Hour))
+	should.Nil(testStore.Add([]byte(`{"url":"/hello4"}`)))
+	// the late file is named before the events of the daily file end
+	should.Nil(testStore.AddAt(epoch.Add(2*time.Hour), []byte(`{"url":"/hello3"}`)))
+	testStore.flushInputQueue()
+	dir := dataFiles()
+	should.Len(dir, 3)
+	should.Equal("201701010000", dir[0].Name())
+	should.Equal("201701010200", dir[1].Name())
+	should.Equal("201701010500", dir[2].Name())
+	iter, err := testStore.Query(epoch.Add(3*time.Hour), epoch.Add(6*time.Hour), 0, 10)
+	should.Nil(err)
+	should.Equal(`{"url":"/hello2"}`, string(iter.Next().Body))
+	should.Equal(`{"url":"/hello4"}`, string(iter.Next().Body))
+	should.False(iter.HasNext())
+}
+
+func Test_flush_loop(t *testing.T) {
+	reset()
+	should := require.New(t)
+	var testStore = newTestStore()
+	should.Nil(testStore.Start())
+	clock.BlockUntil(1)
+	should.Nil(testStore.Add([]byte(`{"url":"/hello1"}`)))
+	should.Len(dataFiles(), 0)
+	clock.Advance(testStore.Config.MaximumFlushInterval)
+	clock.BlockUntil(1)
+	should.Len(dataFiles(), 1)
+	clock.Advance(time.Hour)
+	clock.BlockUntil(1)
+	should.Nil(testStore.Add([]byte(`{"url":"/hello2"}`)))
+	clock.Advance(testStore.Config.MaximumFlushInterval)
+	clock.BlockUntil(1)
+	dir := dataFiles()
+	should.Len(dir, 2)
+	should.Equal("201701010100", dir[1].Name())
+	should.Nil(testStore.Close(context.Background()))
+	clock.BlockUntil(0)
+}
+
+func Test_close_flushes_input_queue(t *testing.T) {
+	reset()
+	should := require.New(t)
+	var testStore = newTestStore()
+	testStore.Config.MaximumFlushInterval = time.Hour
+	should.Nil(testStore.Start())
+	should.Nil(testStore.Add([]byte(`{"url":"/hello1"}`)))
+	should.Nil(testStore.Add([]byte(`{"url":"/hello2"}`)))
+	should.Nil(testStore.Close(context.Background()))
+	should.Equal(ErrStoreClosed, testStore.Add([]byte(`{"url":"/hello3"}`)))
+	should.Equal(ErrStoreClosed, testStore.Close(context.Background()))
+	iter, err := testStore.Query(epoch, epoch.Add(time.Hour), 0, 10)
+	should.Nil(err)
+	should.Equal(`{"url":"/hello1"}`, string(iter.Next().Body))
+	should.Equal(`{"url":"/hello2"}`, string(iter.Next().Body))
+	should.False(iter.HasNext())
+}
+
+func Test_add_at_late_event(t *testing.T) {
+	reset()
+	should := require.New(t)
+	var testStore = newTestStore()
+	clock.Set(epoch.Add(time.Hour))
+	should.Nil(testStore.Add([]byte(`{"url":"/hello2"}`)))
+	should.Nil(testStore.AddAt(epoch, []byte(`{"url":"/hello1"}`)))
+	should.Nil(testStore.Add([]byte(`{"url":"/hello3"}`)))
+	testStore.flushInputQueue()
+	dir := dataFiles()
+	should.Len(dir, 2)
+	should.Equal("201701010000", dir[0].Name())
+	should.Equal("201701010100", dir[1].Name())
+	iter, err := testStore.Query(epoch, epoch.Add(time.Hour), 0, 10)
+	should.Nil(err)
+	event := iter.Next()
+	should.Equal(`{"url":"/hello1"}`, string(event.Body))
+	should.Equal("201701010000", event.BlockId.FileName())
+	should.Equal(epoch, event.Timestamp)
+	event = iter.Next()
+	should.Equal(`{"url":"/hello2"}`, string(event.Body))
+	should.Equal("201701010100", event.BlockId.FileName())
+	should.Equal(`{"url":"/hello3"}`, string(iter.Next().Body))
+	should.False(iter.HasNext())
+	// the current file keeps its block boundaries and base time
+	should.Nil(testStore.Add([]byte(`{"url":"/hello4"}`)))
+	testStore.flushInputQueue()
+	iter, err = testStore.Query(epoch.Add(time.Hour), epoch.Add(time.Hour), 2, 10)
+	should.Nil(err)
+	event = iter.Next()
+	should.Equal(`{"url":"/hello4"}`, string(event.Body))
+	should.Equal(epoch.Add(time.Hour), event.Timestamp)
+}
+
+// timedDiscr records the event timestamps it is called with
+type timedDiscr struct {
+	mockDiscr
+	eventTimestamps *[]time.Time
+}
+
+func (td *timedDiscr) SceneOfAt(eventTS time.Time, eventBody discr.EventBody) discr.Scene {
+	*td.eventTimestamps = append(*td.eventTimestamps, eventTS)
+	return discr.Scene{}
+}
+
+func Test_add_at_samples_by_event_timestamp(t *testing.T) {
+	reset()
+	should := require.New(t)
+	var eventTimestamps []time.Time
+	original := discr.NewDiscrminator
+	defer func() {
+		discr.NewDiscrminator = original
+	}()
+	discr.NewDiscrminator = func() discr.Discrminator {
+		return &timedDiscr{eventTimestamps: &eventTimestamps}
+	}
+	var testStore = newTestStore()
+	clock.Set(epoch.Add(time.Hour))
+	should.Nil(testStore.AddAt(epoch.Add(time.Hour-time.Second), []byte(`{"url":"/hello1"}`)))
+	should.Nil(testStore.AddAt(epoch, []byte(`{"url":"/hello2"}`)))
+	testStore.flushInputQueue()
+	should.Equal([]time.Time{epoch.Add(time.Hour - time.Second), epoch}, eventTimestamps)
+}
+
+// reservingDiscr keeps the latest event in its reservoir
+type reservingDiscr struct {
+	mockDiscr
+	reserved []discr.ReservedSession
+}
+
+func (rd *reservingDiscr) SceneOfAt(eventTS time.Time, eventBody discr.EventBody) discr.Scene {
+	rd.reserved = []discr.ReservedSession{{EventTS: eventTS, EventBody: eventBody}}
+	return nil
+}
+
+func (rd *reservingDiscr) TakeReserved() []discr.ReservedSession {
+	reserved := rd.reserved
+	rd.reserved = nil
+	return reserved
+}
+
+func Test_reserved_events_written_at_end_of_window(t *testing.T) {
+	reset()
+	should := require.New(t)
+	original := discr.NewDiscrminator
+	defer func() {
+		discr.NewDiscrminator = original
+	}()
+	discr.NewDiscrminator = func() discr.Discrminator {
+		return &reservingDiscr{}
+	}
+	var testStore = newTestStore()
+	should.Nil(testStore.Add([]byte(`{"url":"/hello1"}`)))
+	testStore.flushInputQueue()
+	clock.Advance(time.Minute)
+	should.Nil(testStore.Add([]byte(`{"url":"/hello2"}`)))
+	testStore.flushInputQueue()
+	iter, err := testStore.Query(epoch, epoch.Add(time.Hour), 0, 10)
+	should.Nil(err)
+	should.False(iter.HasNext())
+	// rotated
+	clock.Set(epoch.Add(time.Hour))
+	should.Nil(testStore.Add([]byte(`{"url":"/hello3"}`)))
+	testStore.flushInputQueue()
+	iter, err = testStore.Query(epoch, epoch.Add(time.Hour), 0, 10)
+	should.Nil(err)
+	should.Equal(`{"url":"/hello2"}`, string(iter.Next().Body))
+	should.False(iter.HasNext())
+	// the late events are sampled by the batch
+	should.Nil(testStore.AddAt(epoch.Add(2*time.Minute), []byte(`{"url":"/hello4"}`)))
+	should.Nil(testStore.AddAt(epoch.Add(3*time.Minute), []byte(`{"url":"/hello5"}`)))
+	testStore.flushInputQueue()
+	// the current window is written by Close
+	should.Nil(testStore.Close(context.Background()))
+	iter, err = newTestStore().Query(epoch, epoch.Add(2*time.Hour), 0, 10)
+	should.Nil(err)
+	should.Equal(`{"url":"/hello2"}`, string(iter.Next().Body))
+	event := iter.Next()
+	should.Equal(`{"url":"/hello5"}`, string(event.Body))
+	should.Equal(epoch.Add(3*time.Minute), event.Timestamp)
+	should.Equal(`{"url":"/hello3"}`, string(iter.Next().Body))
+	should.False(iter.HasNext())
+}
+
+func Test_add_at_appends_to_existing_file(t *testing.T) {
+	reset()
+	should := require.New(t)
+	var testStore = newTestStore()
+	should.Nil(testStore.Add([]byte(`{"url":"/hello1"}`)))
+	testStore.flushInputQueue()
+	clock.Set(epoch.Add(time.Hour))
+	should.Nil(testStore.Add([]byte(`{"url":"/hello3"}`)))
+	testStore.flushInputQueue()
+	should.Nil(testStore.AddAt(epoch.Add(time.Minute), []byte(`{"url":"/hello2"}`)))
+	testStore.flushInputQueue()
+	should.Len(dataFiles(), 2)
+	iter, err := testStore.Query(epoch, epoch.Add(time.Hour), 0, 10)
+	should.Nil(err)
+	should.Equal(`{"url":"/hello1"}`, string(iter.Next().Body))
+	event := iter.Next()
+	should.Equal(`{"url":"/hello2"}`, string(event.Body))
+	should.Equal(epoch.Add(time.Minute), event.Timestamp)
+	should.Equal(`{"url":"/hello3"}`, string(iter.Next().Body))
+	should.False(iter.HasNext())
+	corrupted, err := testStore.Verify()
+	should.Nil(err)
+	should.Len(corrupted, 0)
+}
+
+func Test_add_at_future_event(t *testing.T) {
+	reset()
+	should := require.New(t)
+	var testStore = newTestStore()
+	should.Equal(ErrFutureEvent, testStore.AddAt(epoch.Add(time.Hour), []byte(`{"url":"/hello"}`)))
+	should.Nil(testStore.AddAt(epoch.Add(time.Minute), []byte(`{"url":"/hello"}`)))
+}
+
+func Test_add_at_expired_event(t *testing.T) {
+	reset()
+	should := require.New(t)
+	var testStore = newTestStore()
+	should.Equal(ErrExpiredEvent, testStore.AddAt(time.Unix(-1, 0), []byte(`{"url":"/hello"}`)))
+	testStore.Config.MaxAge = time.Hour
+	should.Equal(ErrExpiredEvent, testStore.AddAt(epoch.Add(-2*time.Hour), []byte(`{"url":"/hello"}`)))
+	should.Nil(testStore.AddAt(epoch.Add(-30*time.Minute), []byte(`{"url":"/hello"}`)))
+}
+
+func Test_unstorable_late_event(t *testing.T) {
+	reset()
+	should := require.New(t)
+	var testStore = newTestStore()
+	// bypassing the validation of AddAt
+	testStore.lateInputs = []evtInput{
+		{eventTS: time.Unix(-1, 0), eventBody: []byte(`{"url":"/hello1"}`)},
+		{eventTS: epoch.Add(-time.Hour), eventBody: []byte(`{"url":"/hello2"}`)},
+	}
+	should.Equal(1, testStore.flushLateInputs(&blockBuilder{}))
+	files := dataFiles()
+	should.Len(files, 1)
+	should.Equal(testStore.fileNameOf(testStore.windowStart(epoch.Add(-time.Hour))), files[0].Name())
+	_, err := testStore.openDataFileFor(time.Unix(-1, 0))
+	should.Equal(errUnstorableTime, err)
+	should.Len(dataFiles(), 1)
+}
+
+func Test_add_at_future_event_keeps_dedup(t *testing.T) {
+	reset()
+	defer useCountingDiscr()()
+	should := require.New(t)
+	var testStore = newTestStore()
+	should.Equal(ErrFutureEvent, testStore.AddAt(epoch.Add(time.Hour), []byte(`{"url":"/hello"}`)))
+	should.Nil(testStore.AddAt(epoch.Add(30*time.Minute), []byte(`{"url":"/hello"}`)))
+	testStore.flushInputQueue()
+	for i := 0; i < 3; i++ {
+		should.Nil(testStore.Add([]byte(`{"url":"/hello"}`)))
+		testStore.flushInputQueue()
+	}
+	// the real-time events are not late, so they share the discriminator of the window
+	should.Len(testStore.lateInputs, 0)
+	should.Len(dataFiles(), 1)
+	iter, err := testStore.Query(epoch, epoch.Add(time.Hour), 0, 10)
+	should.Nil(err)
+	should.True(iter.HasNext())
+	iter.Next()
+	should.False(iter.HasNext())
+}
+
 func Test_clean(t *testing.T) {
 	reset()
 	should := require.New(t)
-	var testStore = NewStore("/tmp")
+	var testStore = newTestStore()
 	testStore.Config.KeepFilesCount = 1
 	should.Nil(testStore.Add([]byte(`{"url":"/hello"}`)))
 	testStore.flushInputQueue()
-	timeutil.MockNow(timeutil.Now().Add(time.Hour))
+	clock.Set(clock.Now().Add(time.Hour))
 	should.Nil(testStore.Add([]byte(`{"url":"/hello"}`)))
 	testStore.flushInputQueue()
 	testStore.clean()
-	dir, _ := fs.ReadDir("/tmp")
+	dir := dataFiles()
 	should.Len(dir, 1)
-	should.Equal("201701010900", dir[0].Name())
+	should.Equal("201701010100", dir[0].Name())
 }
 
 func Test_list_skip_and_limit(t *testing.T) {
 	reset()
 	should := require.New(t)
-	var testStore = NewStore("/tmp")
+	var testStore = newTestStore()
 	should.Nil(testStore.Add([]byte(`{"url":"/hello1"}`)))
 	testStore.flushInputQueue()
 	should.Nil(testStore.Add([]byte(`{"url":"/hello2"}`)))
 	testStore.flushInputQueue()
-	events, err := testStore.List(timeutil.Now(), timeutil.Now().Add(time.Hour*24), 1, 1)
+	events, err := testStore.List(clock.Now(), clock.Now().Add(time.Hour*24), 1, 1)
 	should.Nil(err)
 	blockId, block, events := events.Next()
-	should.Equal("201701010800", blockId.FileName())
-	should.Equal(uint64(0x46), blockId.Offset())
-	entries := block.EventEntries()
+	should.Equal("201701010000", blockId.FileName())
+	should.Equal(uint64(0x4F), blockId.Offset())
+	entries, err := block.Decompress()
+	should.Nil(err)
 	entry, entries := entries.Next()
 	should.Equal(`{"url":"/hello2"}`, string(entry.EventBody()))
 }
@@ -123,24 +489,25 @@ func Test_list_skip_and_limit(t *testing.T) {
 func Test_list_time_range(t *testing.T) {
 	reset()
 	should := require.New(t)
-	var testStore = NewStore("/tmp")
-	today := timeutil.Now()
+	var testStore = newTestStore()
+	today := clock.Now()
 	yesterday := today.Add(-time.Hour * 24)
-	timeutil.MockNow(yesterday)
+	clock.Set(yesterday)
 	testStore.flushInputQueue()
 	should.Nil(testStore.Add([]byte(`{"url":"/hello1"}`)))
-	timeutil.MockNow(today)
+	clock.Set(today)
 	should.Nil(testStore.Add([]byte(`{"url":"/hello2"}`)))
 	testStore.flushInputQueue()
-	timeutil.MockNow(today.Add(time.Minute * 2))
+	clock.Set(today.Add(time.Minute * 2))
 	should.Nil(testStore.Add([]byte(`{"url":"/hello3"}`)))
 	testStore.flushInputQueue()
 	events, err := testStore.List(today, today.Add(time.Minute), 0, 10)
 	should.Nil(err)
 	blockId, block, events := events.Next()
-	should.Equal("201701010800", blockId.FileName())
-	should.Equal(uint64(0x19), blockId.Offset())
-	entries := block.EventEntries()
+	should.Equal("201701010000", blockId.FileName())
+	should.Equal(uint64(0x1E), blockId.Offset())
+	entries, err := block.Decompress()
+	should.Nil(err)
 	entry, entries := entries.Next()
 	should.Equal(`{"url":"/hello2"}`, string(entry.EventBody()))
 	should.Len(entries, 0)
@@ -0,0 +1,61 @@
+package evtstore
+
+import (
+	"time"
+	"github.com/v2pro/plz/countlog"
+)
+
+// SyncPolicy decides when the saved blocks are synced to disk, trading throughput for durability
+type SyncPolicy int
+
+const (
+	// SyncNever leaves it to the operating system
+	SyncNever SyncPolicy = iota
+	// SyncEveryBlock syncs after each saved block
+	SyncEveryBlock
+	// SyncPeriodically syncs at most once every Config.SyncInterval
+	SyncPeriodically
+	// SyncOnRotation syncs the file before switching to next one
+	SyncOnRotation
+)
+
+// syncAfterBlock is called by saveBlock, the failure to sync does not fail the saved block
+func (store *Store) syncAfterBlock() {
+	switch store.Config.SyncPolicy {
+	case SyncEveryBlock:
+		store.syncCurrentFileOrLog()
+	case SyncPeriodically:
+		store.syncIfDue()
+	}
+}
+
+// syncIfDue syncs the blocks saved before last flush interval
+func (store *Store) syncIfDue() {
+	if store.Config.SyncPolicy != SyncPeriodically || !store.dirty {
+		return
+	}
+	if store.clock.Now().Sub(store.lastSyncTime) < store.Config.SyncInterval {
+		return
+	}
+	store.syncCurrentFileOrLog()
+}
+
+func (store *Store) syncCurrentFileOrLog() {
+	err := store.syncCurrentFile()
+	if err != nil {
+		countlog.Error("event!failed to sync file", "err", err, "fileName", store.current.file.Name())
+	}
+}
+
+func (store *Store) syncCurrentFile() error {
+	startSyncTime := time.Now()
+	err := store.current.file.Sync()
+	if err != nil {
+		return err
+	}
+	store.dirty = false
+	store.lastSyncTime = store.clock.Now()
+	countlog.Debug("event!store.synced", "latency", time.Since(startSyncTime),
+		"fileName", store.current.file.Name())
+	return nil
+}
@@ -0,0 +1,57 @@
+package evtstore
+
+import (
+	"testing"
+	"github.com/stretchr/testify/require"
+	"github.com/blang/vfs"
+	"os"
+	"time"
+)
+
+type syncCountingFS struct {
+	vfs.Filesystem
+	syncCount int
+}
+
+func (countingFS *syncCountingFS) OpenFile(name string, flag int, perm os.FileMode) (vfs.File, error) {
+	file, err := countingFS.Filesystem.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &syncCountingFile{File: file, fs: countingFS}, nil
+}
+
+type syncCountingFile struct {
+	vfs.File
+	fs *syncCountingFS
+}
+
+func (file *syncCountingFile) Sync() error {
+	file.fs.syncCount++
+	return file.File.Sync()
+}
+
+func countSyncs(should *require.Assertions, policy SyncPolicy, syncInterval time.Duration) []int {
+	reset()
+	countingFS := &syncCountingFS{Filesystem: fs}
+	fs = countingFS
+	var testStore = newTestStore()
+	testStore.Config.SyncPolicy = policy
+	testStore.Config.SyncInterval = syncInterval
+	var syncCounts []int
+	for _, ts := range []time.Time{epoch, epoch.Add(time.Second), epoch.Add(time.Hour)} {
+		clock.Set(ts)
+		should.Nil(testStore.Add([]byte(`{"url":"/hello"}`)))
+		testStore.flushInputQueue()
+		syncCounts = append(syncCounts, countingFS.syncCount)
+	}
+	return syncCounts
+}
+
+func Test_sync_policy(t *testing.T) {
+	should := require.New(t)
+	should.Equal([]int{0, 0, 0}, countSyncs(should, SyncNever, 0))
+	should.Equal([]int{1, 2, 3}, countSyncs(should, SyncEveryBlock, 0))
+	should.Equal([]int{1, 1, 2}, countSyncs(should, SyncPeriodically, time.Hour))
+	should.Equal([]int{0, 0, 1}, countSyncs(should, SyncOnRotation, 0))
+}
@@ -0,0 +1,110 @@
+package evtstore
+
+import (
+	"hash/crc32"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path"
+	"github.com/blang/vfs"
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+var errCorruptedBlock = errors.New("checksum mismatch")
+
+func checksumOf(compressedEventEntries []byte) uint32 {
+	return crc32.Checksum(compressedEventEntries, crc32cTable)
+}
+
+// storedChecksum reads the checksum of block header stored in file of format version 2
+func storedChecksum(header EventBlock) uint32 {
+	return binary.LittleEndian.Uint32(header[blockHeaderSize:])
+}
+
+// readBlock reads the stored block into buf, which must be of the stored size.
+// The checksum is verified and stripped from the returned block.
+func readBlock(file vfs.File, fileHeader fileHeader, entry blockIndexEntry, buf []byte) (EventBlock, error) {
+	headerSize := fileHeader.BlockHeaderSize()
+	_, err := file.ReadAt(buf, entry.Offset())
+	if err != nil {
+		return nil, err
+	}
+	if fileHeader.Version() > 1 && checksumOf(buf[headerSize:]) != storedChecksum(buf) {
+		return nil, errCorruptedBlock
+	}
+	// move the header next to the body, over the checksum
+	copy(buf[headerSize-blockHeaderSize:], buf[:blockHeaderSize])
+	return EventBlock(buf[headerSize-blockHeaderSize:]), nil
+}
+
+type CorruptedBlock struct {
+	FileName string
+	Offset   int64
+	Reason   string
+}
+
+// Verify reads through all the blocks in store, the corrupted ones are reported
+// instead of stopping at the first one
+func (store *Store) Verify() ([]CorruptedBlock, error) {
+	files, err := store.dataFiles()
+	if err != nil {
+		return nil, err
+	}
+	var corruptedBlocks []CorruptedBlock
+	for _, fileInfo := range files {
+		corrupted, err := store.verifyFile(fileInfo.Name())
+		if err != nil {
+			return nil, err
+		}
+		corruptedBlocks = append(corruptedBlocks, corrupted...)
+	}
+	return corruptedBlocks, nil
+}
+
+func (store *Store) verifyFile(fileName string) ([]CorruptedBlock, error) {
+	filePath := path.Join(store.RootDir, fileName)
+	file, err := store.fs.OpenFile(filePath, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	stat, err := store.fs.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	fileHeader, err := readFileHeader(file)
+	if err != nil {
+		return []CorruptedBlock{{FileName: fileName, Offset: 0, Reason: err.Error()}}, nil
+	}
+	index, validSize, err := scanIndex(file, stat.Size(), fileHeader)
+	if err != nil {
+		return nil, err
+	}
+	var corruptedBlocks []CorruptedBlock
+	for i := 0; i < index.Len(); i++ {
+		entry := index.Entry(i)
+		err = verifyBlock(file, fileHeader, entry)
+		if err != nil {
+			corruptedBlocks = append(corruptedBlocks, CorruptedBlock{
+				FileName: fileName, Offset: entry.Offset(), Reason: err.Error()})
+		}
+	}
+	if validSize < stat.Size() {
+		corruptedBlocks = append(corruptedBlocks, CorruptedBlock{
+			FileName: fileName, Offset: validSize, Reason: "torn block"})
+	}
+	return corruptedBlocks, nil
+}
+
+// verifyBlock checks the block by checksum, and by decompressing as the block of format version 1
+// has no checksum
+func verifyBlock(file vfs.File, fileHeader fileHeader, entry blockIndexEntry) error {
+	buf := make([]byte, fileHeader.BlockHeaderSize()+int64(entry.CompressedSize()))
+	block, err := readBlock(file, fileHeader, entry, buf)
+	if err != nil {
+		return err
+	}
+	_, err = block.Decompress()
+	return err
+}
@@ -0,0 +1,111 @@
+package evtstore
+
+import (
+	"testing"
+	"github.com/stretchr/testify/require"
+	"github.com/blang/vfs"
+	"github.com/v2pro/quoll/lz4"
+	"github.com/v2pro/quoll/timeutil"
+	"encoding/binary"
+	"os"
+	"time"
+)
+
+func Test_list_skip_corrupted_block(t *testing.T) {
+	reset()
+	should := require.New(t)
+	var testStore = newTestStore()
+	should.Nil(testStore.Add([]byte(`{"url":"/hello1"}`)))
+	testStore.flushInputQueue()
+	should.Nil(testStore.Add([]byte(`{"url":"/hello2"}`)))
+	testStore.flushInputQueue()
+	corruptByte(should, "/tmp/201701010000", 0x1E+5)
+	events, err := testStore.List(epoch, epoch.Add(time.Hour), 0, 10)
+	should.Nil(err)
+	blockId, block, events := events.Next()
+	should.Equal(uint64(0x4F), blockId.Offset())
+	entries, err := block.Decompress()
+	should.Nil(err)
+	entry, _ := entries.Next()
+	should.Equal(`{"url":"/hello2"}`, string(entry.EventBody()))
+	should.Len(events, 0)
+}
+
+func Test_verify(t *testing.T) {
+	reset()
+	should := require.New(t)
+	var testStore = newTestStore()
+	should.Nil(testStore.Add([]byte(`{"url":"/hello1"}`)))
+	testStore.flushInputQueue()
+	should.Nil(testStore.Add([]byte(`{"url":"/hello2"}`)))
+	testStore.flushInputQueue()
+	corrupted, err := testStore.Verify()
+	should.Nil(err)
+	should.Len(corrupted, 0)
+	corruptByte(should, "/tmp/201701010000", 0x4F+5)
+	corrupted, err = testStore.Verify()
+	should.Nil(err)
+	should.Equal([]CorruptedBlock{{
+		FileName: "201701010000", Offset: 0x4F - blockHeaderSize - checksumSize, Reason: "checksum mismatch"},
+	}, corrupted)
+}
+
+func Test_read_format_version_1(t *testing.T) {
+	reset()
+	should := require.New(t)
+	writeVersion1File(should, "/tmp/201701010000", []byte(`{"url":"/hello1"}`))
+	var testStore = newTestStore()
+	should.Nil(testStore.Add([]byte(`{"url":"/hello2"}`)))
+	testStore.flushInputQueue()
+	events, err := testStore.List(epoch, epoch.Add(time.Hour), 0, 10)
+	should.Nil(err)
+	blockId, block, events := events.Next()
+	should.Equal(uint64(0x19), blockId.Offset())
+	entries, err := block.Decompress()
+	should.Nil(err)
+	entry, _ := entries.Next()
+	should.Equal(`{"url":"/hello1"}`, string(entry.EventBody()))
+	blockId, block, events = events.Next()
+	should.Equal(uint64(0x46), blockId.Offset())
+	entries, err = block.Decompress()
+	should.Nil(err)
+	entry, _ = entries.Next()
+	should.Equal(`{"url":"/hello2"}`, string(entry.EventBody()))
+	corrupted, err := testStore.Verify()
+	should.Nil(err)
+	should.Len(corrupted, 0)
+}
+
+func writeVersion1File(should *require.Assertions, filePath string, eventBody []byte) {
+	baseTime := time.Unix(clock.Now().Unix()/3600*3600, 0)
+	content := []byte(newFileHeader(1, baseTime, timeutil.DefaultShift))
+	entries := make([]byte, entryHeaderSize, entryHeaderSize+len(eventBody))
+	binary.LittleEndian.PutUint32(entries, uint32(len(eventBody)))
+	binary.LittleEndian.PutUint32(entries[4:], timeutil.Compress(baseTime, clock.Now()))
+	entries = append(entries, eventBody...)
+	compressed := make([]byte, lz4.CompressBound(len(entries)))
+	compressed = compressed[:lz4.CompressDefault(entries, compressed)]
+	header := make([]byte, blockHeaderSize)
+	binary.LittleEndian.PutUint32(header[0:4], uint32(len(compressed)))
+	binary.LittleEndian.PutUint32(header[4:8], uint32(len(entries)))
+	binary.LittleEndian.PutUint16(header[8:10], 1)
+	binary.LittleEndian.PutUint32(header[10:14], binary.LittleEndian.Uint32(entries[4:]))
+	binary.LittleEndian.PutUint32(header[14:18], binary.LittleEndian.Uint32(entries[4:]))
+	content = append(content, header...)
+	content = append(content, compressed...)
+	should.Nil(vfs.WriteFile(fs, filePath, content, 0666))
+}
+
+func corruptByte(should *require.Assertions, filePath string, offset int64) {
+	file, err := fs.OpenFile(filePath, os.O_RDWR, 0)
+	should.Nil(err)
+	b := []byte{0}
+	_, err = file.ReadAt(b, offset)
+	should.Nil(err)
+	b[0] ^= 0xFF
+	_, err = file.Seek(offset, 0)
+	should.Nil(err)
+	_, err = file.Write(b)
+	should.Nil(err)
+	should.Nil(file.Close())
+}
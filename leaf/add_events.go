@@ -0,0 +1,141 @@
+package leaf
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"github.com/json-iterator/go"
+	"github.com/v2pro/quoll/discr"
+	"fmt"
+)
+
+// maxEventSize bounds each event in the batch, so that a broken frame does not eat up the memory
+const maxEventSize = 16 * 1024 * 1024
+
+// maxAddEventsBytes bounds the body of /add-events, larger batches are to be split by the client
+const maxAddEventsBytes = 64 * 1024 * 1024
+
+// maxAddEventsCount bounds the events of one /add-events, larger batches are to be split by the client
+const maxAddEventsCount = 10000
+
+var errTooManyEvents = fmt.Errorf("more than %d events in one request", maxAddEventsCount)
+
+// addEvents accepts a batch of events, delimited by newline (application/x-ndjson, the default),
+// or prefixed by 4 bytes little endian length (application/octet-stream).
+// With atomic=true, the events are all added or none of them, at most the input queue capacity of the store.
+func addEvents(respWriter http.ResponseWriter, req *http.Request) {
+	store, err := storeOf(req)
+	if err != nil {
+		writeError(respWriter, err)
+		return
+	}
+	defer req.Body.Close()
+	body := http.MaxBytesReader(respWriter, req.Body, maxAddEventsBytes)
+	var eventBodies []discr.EventBody
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if mediaType == "application/octet-stream" {
+		eventBodies, err = readLengthPrefixed(body)
+	} else {
+		eventBodies, err = readNDJSON(body)
+	}
+	if err != nil {
+		writeError(respWriter, err)
+		return
+	}
+	errno := 0
+	errmsg := ""
+	itemErrors := []map[string]interface{}{}
+	if req.URL.Query().Get("atomic") == "true" {
+		// never fits in the queue, the client has to split it instead of retrying
+		if len(eventBodies) > store.Config.InputQueueCapacity {
+			writeError(respWriter, fmt.Errorf("atomic batch of %d events is more than the input queue capacity %d",
+				len(eventBodies), store.Config.InputQueueCapacity))
+			return
+		}
+		err = store.AddAll(eventBodies)
+		if err != nil {
+			errno = 1
+			errmsg = err.Error()
+		}
+	} else {
+		ctx, cancel := context.WithTimeout(req.Context(), addEventTimeout)
+		defer cancel()
+		for i, err := range store.AddBatch(ctx, eventBodies) {
+			err = overflowIfTimedOut(err)
+			if err != nil {
+				itemErrors = append(itemErrors, map[string]interface{}{
+					"index":  i,
+					"errmsg": err.Error(),
+				})
+			}
+		}
+	}
+	rejected := len(itemErrors)
+	if errno != 0 {
+		rejected = len(eventBodies)
+	}
+	resp, err := jsoniter.Marshal(map[string]interface{}{
+		"errno":    errno,
+		"errmsg":   errmsg,
+		"accepted": len(eventBodies) - rejected,
+		"rejected": rejected,
+		"errors":   itemErrors,
+	})
+	if err != nil {
+		writeError(respWriter, err)
+		return
+	}
+	respWriter.Write(resp)
+}
+
+func readNDJSON(reader io.Reader) ([]discr.EventBody, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxEventSize)
+	var eventBodies []discr.EventBody
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		if len(eventBodies) == maxAddEventsCount {
+			return nil, errTooManyEvents
+		}
+		// the scanner reuses its buffer
+		eventBodies = append(eventBodies, append(discr.EventBody(nil), line...))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return eventBodies, nil
+}
+
+func readLengthPrefixed(reader io.Reader) ([]discr.EventBody, error) {
+	var eventBodies []discr.EventBody
+	var sizeBuf [4]byte
+	for {
+		_, err := io.ReadFull(reader, sizeBuf[:])
+		if err == io.EOF {
+			return eventBodies, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(eventBodies) == maxAddEventsCount {
+			return nil, errTooManyEvents
+		}
+		size := binary.LittleEndian.Uint32(sizeBuf[:])
+		if size > maxEventSize {
+			return nil, errors.New("event size exceeds limit")
+		}
+		eventBody := make(discr.EventBody, size)
+		_, err = io.ReadFull(reader, eventBody)
+		if err != nil {
+			return nil, err
+		}
+		eventBodies = append(eventBodies, eventBody)
+	}
+}
@@ -0,0 +1,79 @@
+package leaf
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+)
+
+type addEventsResp struct {
+	Errno    int
+	Errmsg   string
+	Accepted int
+	Rejected int
+}
+
+func callAddEvents(should *require.Assertions, url string, contentType string, body []byte) addEventsResp {
+	req := httptest.NewRequest("POST", url, bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	recorder := httptest.NewRecorder()
+	addEvents(recorder, req)
+	var resp addEventsResp
+	should.Nil(jsoniter.Unmarshal(recorder.Body.Bytes(), &resp))
+	return resp
+}
+
+func Test_add_events(t *testing.T) {
+	should := require.New(t)
+	store := addGrpcTestStore(should, "add-events")
+	defer store.Close(context.Background())
+	resp := callAddEvents(should, "/add-events?store=add-events", "application/x-ndjson",
+		[]byte("{\"url\":\"/hello1\"}\n\n{\"url\":\"/hello2\"}\n"))
+	should.Equal(addEventsResp{Accepted: 2}, resp)
+	var body []byte
+	for _, event := range []string{`{"url":"/hello3"}`, `{"url":"/hello4"}`} {
+		var sizeBuf [4]byte
+		binary.LittleEndian.PutUint32(sizeBuf[:], uint32(len(event)))
+		body = append(append(body, sizeBuf[:]...), event...)
+	}
+	resp = callAddEvents(should, "/add-events?store=add-events&atomic=true", "application/octet-stream", body)
+	should.Equal(addEventsResp{Accepted: 2}, resp)
+}
+
+func Test_add_events_limits(t *testing.T) {
+	should := require.New(t)
+	store := addGrpcTestStore(should, "add-events-limits")
+	defer store.Close(context.Background())
+	resp := callAddEvents(should, "/add-events?store=add-events-limits", "application/x-ndjson",
+		[]byte(strings.Repeat("{}\n", maxAddEventsCount+1)))
+	should.Equal(1, resp.Errno)
+	should.Equal(errTooManyEvents.Error(), resp.Errmsg)
+	var sizeBuf [4]byte
+	binary.LittleEndian.PutUint32(sizeBuf[:], 2)
+	resp = callAddEvents(should, "/add-events?store=add-events-limits", "application/octet-stream",
+		bytes.Repeat(append(sizeBuf[:], "{}"...), maxAddEventsCount+1))
+	should.Equal(1, resp.Errno)
+	should.Equal(errTooManyEvents.Error(), resp.Errmsg)
+	// the body is not read beyond the limit
+	event := append(bytes.Repeat([]byte{' '}, maxEventSize-3), "{}\n"...)
+	resp = callAddEvents(should, "/add-events?store=add-events-limits", "application/x-ndjson",
+		bytes.Repeat(event, maxAddEventsBytes/maxEventSize+1))
+	should.Equal(1, resp.Errno)
+	should.Contains(resp.Errmsg, "too large")
+	should.Equal(uint64(0), store.DroppedCounters().Rejected)
+	// the atomic batch fits in the input queue
+	capacity := store.Config.InputQueueCapacity
+	resp = callAddEvents(should, "/add-events?store=add-events-limits&atomic=true", "application/x-ndjson",
+		[]byte(strings.Repeat("{}\n", capacity+1)))
+	should.Equal(1, resp.Errno)
+	should.Contains(resp.Errmsg, fmt.Sprintf("input queue capacity %d", capacity))
+	resp = callAddEvents(should, "/add-events?store=add-events-limits&atomic=true", "application/x-ndjson",
+		[]byte(strings.Repeat("{}\n", capacity)))
+	should.Equal(addEventsResp{Accepted: capacity}, resp)
+}
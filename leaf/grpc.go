@@ -0,0 +1,194 @@
+package leaf
+
+import (
+	"io"
+	"time"
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"github.com/v2pro/quoll/leaf/leafpb"
+	"github.com/v2pro/quoll/evtstore"
+	"github.com/v2pro/quoll/discr"
+)
+
+// RegisterGrpcService serves the same stores as the http api by the grpc contract of leafpb,
+// the stores are started by RegisterHttpHandlers
+func RegisterGrpcService(server *grpc.Server) {
+	leafpb.RegisterLeafServer(server, &grpcService{})
+}
+
+type grpcService struct {
+}
+
+func (service *grpcService) AddEvents(stream leafpb.Leaf_AddEventsServer) error {
+	resp := &leafpb.AddEventsResponse{}
+	var store *evtstore.Store
+	index := int64(0)
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(resp)
+		}
+		if err != nil {
+			return err
+		}
+		if store == nil {
+			store, err = storeNamed(req.Store)
+			if err != nil {
+				return status.Error(codes.NotFound, err.Error())
+			}
+		}
+		for _, event := range req.Events {
+			err = addGrpcEvent(stream.Context(), store, event)
+			if err != nil {
+				resp.Rejected++
+				resp.Errors = append(resp.Errors, &leafpb.AddEventError{Index: index, Message: err.Error()})
+			} else {
+				resp.Accepted++
+			}
+			index++
+		}
+	}
+}
+
+func addGrpcEvent(ctx context.Context, store *evtstore.Store, event *leafpb.Event) error {
+	ctx, cancel := context.WithTimeout(ctx, addEventTimeout)
+	defer cancel()
+	if event.Timestamp == 0 {
+		return overflowIfTimedOut(store.AddContext(ctx, event.Body))
+	}
+	return overflowIfTimedOut(store.AddAtContext(ctx, unixMilli(event.Timestamp), event.Body))
+}
+
+// ListEvents queries the events in pages of limit, grouping the consecutive events of same block into one message
+func (service *grpcService) ListEvents(req *leafpb.ListEventsRequest, stream leafpb.Leaf_ListEventsServer) error {
+	store, err := storeNamed(req.Store)
+	if err != nil {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	startTime := time.Now().Add(-time.Hour)
+	if req.StartTime != 0 {
+		startTime = unixMilli(req.StartTime)
+	}
+	endTime := time.Now()
+	if req.EndTime != 0 {
+		endTime = unixMilli(req.EndTime)
+	}
+	limit := 10
+	if req.Limit != 0 {
+		limit = int(req.Limit)
+	}
+	var cursor evtstore.Cursor
+	if len(req.Cursor) > 0 {
+		cursor, err = evtstore.ParseCursor(req.Cursor)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	iter, _, err := store.QueryAfter(cursor, startTime, endTime, int(req.Skip), limit)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	var block *leafpb.EventBlock
+	var blockId evtstore.EventBlockId
+	for iter.HasNext() {
+		event := iter.Next()
+		if block != nil && string(event.BlockId) != string(blockId) {
+			if err := stream.Send(block); err != nil {
+				return err
+			}
+			block = nil
+		}
+		if block == nil {
+			blockId = event.BlockId
+			block = &leafpb.EventBlock{FileName: blockId.FileName(), Offset: blockId.Offset()}
+		}
+		block.Events = append(block.Events, &leafpb.Event{
+			Timestamp: event.Timestamp.UnixNano() / int64(time.Millisecond),
+			Body:      event.Body,
+		})
+		block.NextCursor = event.Cursor()
+	}
+	if block != nil {
+		return stream.Send(block)
+	}
+	return nil
+}
+
+func (service *grpcService) Tail(req *leafpb.TailRequest, stream leafpb.Leaf_TailServer) error {
+	filter := discr.SubscribeFilter{
+		SessionType: req.SessionType,
+		Matcher:     sessionMatcherCnfOf(req.Matcher),
+		Where:       sceneFilterCnfOf(req.Filter),
+	}
+	err := discr.TailSessions(stream.Context(), filter, int(req.Limit),
+		func(tailed discr.TailedSession) error {
+			msg := &leafpb.TailedSession{SessionType: tailed.SessionType}
+			if tailed.MatchErr != nil {
+				msg.MatchError = tailed.MatchErr.Error()
+			} else if tailed.Scene != nil {
+				msg.Scene = tailed.Scene.ToMap()
+			}
+			if req.ShowSession {
+				msg.Session = tailed.Session
+			}
+			return stream.Send(msg)
+		})
+	switch err {
+	case discr.ErrTailLimitReached, discr.ErrTailTimeout:
+		return nil
+	}
+	return err
+}
+
+func (service *grpcService) UpdateSessionMatcher(ctx context.Context,
+	req *leafpb.SessionMatcher) (*leafpb.UpdateSessionMatcherResponse, error) {
+	err := updateAndSaveSessionMatcher(sessionMatcherCnfOf(req))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &leafpb.UpdateSessionMatcherResponse{}, nil
+}
+
+func sessionMatcherCnfOf(matcher *leafpb.SessionMatcher) discr.SessionMatcherCnf {
+	if matcher == nil {
+		return discr.SessionMatcherCnf{}
+	}
+	cnf := discr.SessionMatcherCnf{
+		SessionType:             matcher.SessionType,
+		KeepNSessionsPerScene:   int(matcher.KeepNSessionsPerScene),
+		InboundRequestPatterns:  matcher.InboundRequestPatterns,
+		InboundResponsePatterns: matcher.InboundResponsePatterns,
+	}
+	if matcher.Sampling != nil {
+		cnf.Sampling = discr.SamplingCnf{
+			Strategy:    matcher.Sampling.Strategy,
+			Probability: matcher.Sampling.Probability,
+			PerMinute:   int(matcher.Sampling.PerMinute),
+		}
+	}
+	for _, callOutbound := range matcher.CallOutbounds {
+		cnf.CallOutbounds = append(cnf.CallOutbounds, discr.CallOutboundMatcherCnf{
+			ServiceName:      callOutbound.ServiceName,
+			RequestPatterns:  callOutbound.RequestPatterns,
+			ResponsePatterns: callOutbound.ResponsePatterns,
+		})
+	}
+	return cnf
+}
+
+func sceneFilterCnfOf(filter *leafpb.SceneFilter) discr.SceneFilterCnf {
+	if filter == nil {
+		return discr.SceneFilterCnf{}
+	}
+	return discr.SceneFilterCnf{
+		SceneEquals:     filter.SceneEquals,
+		SceneMatched:    filter.SceneMatched,
+		SessionPatterns: filter.SessionPatterns,
+	}
+}
+
+func unixMilli(ms int64) time.Time {
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
@@ -0,0 +1,186 @@
+package leaf
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+	"github.com/blang/vfs/memfs"
+	"github.com/stretchr/testify/require"
+	"github.com/v2pro/quoll/discr"
+	"github.com/v2pro/quoll/evtstore"
+	"github.com/v2pro/quoll/leaf/leafpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// startGrpcTest serves the grpc service in memory, the returned channel receives the error of each finished stream
+func startGrpcTest(should *require.Assertions) (leafpb.LeafClient, chan error, func()) {
+	listener := bufconn.Listen(1024 * 1024)
+	streamErrs := make(chan error, 16)
+	server := grpc.NewServer(grpc.StreamInterceptor(func(srv interface{}, stream grpc.ServerStream,
+		info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		err := handler(srv, stream)
+		streamErrs <- err
+		return err
+	}))
+	RegisterGrpcService(server)
+	go server.Serve(listener)
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	should.Nil(err)
+	return leafpb.NewLeafClient(conn), streamErrs, func() {
+		conn.Close()
+		server.Stop()
+	}
+}
+
+// addGrpcTestStore hosts a store in memory, which only flushes when closed,
+// so that the events added by one stream are saved as one block
+func addGrpcTestStore(should *require.Assertions, name string) *evtstore.Store {
+	fs := memfs.Create()
+	should.Nil(fs.Mkdir("/"+name, 0777))
+	store := evtstore.NewStore("/"+name, evtstore.WithFilesystem(fs))
+	store.Config.MaximumFlushInterval = time.Hour
+	should.Nil(AddStore(name, store))
+	should.Nil(store.Start())
+	return store
+}
+
+func Test_grpc_add_events(t *testing.T) {
+	should := require.New(t)
+	store := addGrpcTestStore(should, "grpc-add")
+	defer store.Close(context.Background())
+	client, _, stop := startGrpcTest(should)
+	defer stop()
+	stream, err := client.AddEvents(context.Background())
+	should.Nil(err)
+	future := time.Now().Add(3*time.Hour).UnixNano() / int64(time.Millisecond)
+	should.Nil(stream.Send(&leafpb.AddEventsRequest{Store: "grpc-add", Events: []*leafpb.Event{
+		{Body: []byte(`{"url":"/hello1"}`)},
+		{Timestamp: future, Body: []byte(`{"url":"/future"}`)},
+	}}))
+	// the store is only read from the first message
+	should.Nil(stream.Send(&leafpb.AddEventsRequest{Store: "unknown", Events: []*leafpb.Event{
+		{Body: []byte(`{"url":"/hello2"}`)},
+		{Timestamp: future, Body: []byte(`{"url":"/future"}`)},
+	}}))
+	resp, err := stream.CloseAndRecv()
+	should.Nil(err)
+	should.Equal(int64(2), resp.Accepted)
+	should.Equal(int64(2), resp.Rejected)
+	should.Len(resp.Errors, 2)
+	// the index counts across the messages of the stream
+	should.Equal(int64(1), resp.Errors[0].Index)
+	should.Equal(evtstore.ErrFutureEvent.Error(), resp.Errors[0].Message)
+	should.Equal(int64(3), resp.Errors[1].Index)
+}
+
+func Test_grpc_add_events_unknown_store(t *testing.T) {
+	should := require.New(t)
+	client, _, stop := startGrpcTest(should)
+	defer stop()
+	stream, err := client.AddEvents(context.Background())
+	should.Nil(err)
+	should.Nil(stream.Send(&leafpb.AddEventsRequest{Store: "unknown", Events: []*leafpb.Event{
+		{Body: []byte(`{"url":"/hello"}`)},
+	}}))
+	_, err = stream.CloseAndRecv()
+	should.Equal(codes.NotFound, status.Code(err))
+}
+
+func listGrpcEvents(should *require.Assertions, client leafpb.LeafClient, req *leafpb.ListEventsRequest) []*leafpb.EventBlock {
+	stream, err := client.ListEvents(context.Background(), req)
+	should.Nil(err)
+	var blocks []*leafpb.EventBlock
+	for {
+		block, err := stream.Recv()
+		if err == io.EOF {
+			return blocks
+		}
+		should.Nil(err)
+		blocks = append(blocks, block)
+	}
+}
+
+// keepAllDiscr stores every event, instead of one session per scene
+type keepAllDiscr struct {
+}
+
+func (discriminator *keepAllDiscr) SceneOf(eventBody discr.EventBody) discr.Scene {
+	return discr.Scene{}
+}
+
+func Test_grpc_list_events(t *testing.T) {
+	should := require.New(t)
+	original := discr.NewDiscrminator
+	defer func() {
+		discr.NewDiscrminator = original
+	}()
+	discr.NewDiscrminator = func() discr.Discrminator {
+		return &keepAllDiscr{}
+	}
+	store := addGrpcTestStore(should, "grpc-list")
+	client, _, stop := startGrpcTest(should)
+	defer stop()
+	stream, err := client.AddEvents(context.Background())
+	should.Nil(err)
+	should.Nil(stream.Send(&leafpb.AddEventsRequest{Store: "grpc-list", Events: []*leafpb.Event{
+		{Body: []byte(`{"url":"/hello1"}`)},
+		{Body: []byte(`{"url":"/hello2"}`)},
+		{Body: []byte(`{"url":"/hello3"}`)},
+	}}))
+	resp, err := stream.CloseAndRecv()
+	should.Nil(err)
+	should.Equal(int64(3), resp.Accepted)
+	should.Nil(store.Close(context.Background()))
+	// the events of same block are grouped into one message
+	blocks := listGrpcEvents(should, client, &leafpb.ListEventsRequest{Store: "grpc-list"})
+	should.Len(blocks, 1)
+	should.Len(blocks[0].Events, 3)
+	should.Equal(`{"url":"/hello1"}`, string(blocks[0].Events[0].Body))
+	// continue after the cursor of the last page
+	blocks = listGrpcEvents(should, client, &leafpb.ListEventsRequest{Store: "grpc-list", Limit: 2})
+	should.Len(blocks, 1)
+	should.Len(blocks[0].Events, 2)
+	blocks = listGrpcEvents(should, client, &leafpb.ListEventsRequest{
+		Store: "grpc-list", Limit: 2, Cursor: blocks[0].NextCursor})
+	should.Len(blocks, 1)
+	should.Len(blocks[0].Events, 1)
+	should.Equal(`{"url":"/hello3"}`, string(blocks[0].Events[0].Body))
+	badCursor, err := client.ListEvents(context.Background(),
+		&leafpb.ListEventsRequest{Store: "grpc-list", Cursor: []byte("bad")})
+	should.Nil(err)
+	_, err = badCursor.Recv()
+	should.Equal(codes.InvalidArgument, status.Code(err))
+}
+
+func Test_grpc_tail_cancel(t *testing.T) {
+	should := require.New(t)
+	client, streamErrs, stop := startGrpcTest(should)
+	defer stop()
+	defer keepCollecting("/grpc-tail")()
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.Tail(ctx, &leafpb.TailRequest{SessionType: "/grpc-tail", ShowSession: true})
+	should.Nil(err)
+	tailed, err := stream.Recv()
+	should.Nil(err)
+	should.Equal("/grpc-tail", tailed.SessionType)
+	should.Contains(string(tailed.Session), "REQUEST_URI/grpc-tail")
+	cancel()
+	_, err = stream.Recv()
+	should.Equal(codes.Canceled, status.Code(err))
+	// the tail on server stops with the client
+	select {
+	case <-streamErrs:
+	case <-time.After(5 * time.Second):
+		should.Fail("tail not stopped after the client cancelled")
+	}
+}
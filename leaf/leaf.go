@@ -10,30 +10,84 @@ import (
 	"github.com/json-iterator/go"
 	"time"
 	"strconv"
+	"encoding/base64"
+	"fmt"
+	"context"
+	"html"
+	"errors"
 )
 
-var store = evtstore.NewStore("/tmp/store")
+// addEventTimeout is how long /add-event waits for the full input queue
+const addEventTimeout = time.Second
+
+// overflowIfTimedOut reports the add given up waiting for the full input queue as the overflow,
+// so that the client backs off and retries
+func overflowIfTimedOut(err error) error {
+	if err == context.DeadlineExceeded {
+		return evtstore.ErrInputQueueOverflow
+	}
+	return err
+}
 
 func RegisterHttpHandlers(mux *http.ServeMux) error {
-	err := store.Start()
+	err := addDefaultStore()
+	if err != nil {
+		return err
+	}
+	if sessionMatchersFilePath() == "" {
+		return errors.New("session matchers file is required without default store")
+	}
+	err = loadSessionMatchers()
+	if err != nil {
+		return err
+	}
+	err = startStores()
 	if err != nil {
 		return err
 	}
 	mux.HandleFunc("/add-event", addEvent)
+	mux.HandleFunc("/add-events", addEvents)
 	mux.HandleFunc("/list-events", listEvents)
+	mux.HandleFunc("/get-block", getBlock)
+	mux.HandleFunc("/verify-events", verifyEvents)
+	mux.HandleFunc("/store-stats", storeStats)
+	mux.HandleFunc("/list-scene-stats", listSceneStats)
 	mux.HandleFunc("/update-session-matcher", updateSessionMatcher)
+	mux.HandleFunc("/list-session-matchers", listSessionMatchers)
+	mux.HandleFunc("/delete-session-matcher", deleteSessionMatcher)
 	mux.HandleFunc("/tail", tail)
 	mux.HandleFunc("/", showTailForm)
 	return nil
 }
 
 func addEvent(respWriter http.ResponseWriter, req *http.Request) {
+	store, err := storeOf(req)
+	if err != nil {
+		writeError(respWriter, err)
+		return
+	}
 	eventJson, err := ioutil.ReadAll(req.Body)
 	if err != nil {
 		writeError(respWriter, err)
 		return
 	}
-	err = store.Add(eventJson)
+	ctx, cancel := context.WithTimeout(req.Context(), addEventTimeout)
+	defer cancel()
+	// the event happened now, unless the client tells when
+	eventTSStr := req.Header.Get("X-Event-Timestamp")
+	if eventTSStr == "" {
+		eventTSStr = req.URL.Query().Get("timestamp")
+	}
+	if eventTSStr == "" {
+		err = store.AddContext(ctx, eventJson)
+	} else {
+		var eventTS time.Time
+		eventTS, err = parseTime(eventTSStr, store.Config.Location)
+		if err == nil {
+			err = store.AddAtContext(ctx, eventTS, eventJson)
+		}
+	}
+	err = overflowIfTimedOut(err)
 	if err != nil {
 		writeError(respWriter, err)
 		return
@@ -42,12 +96,16 @@ func addEvent(respWriter http.ResponseWriter, req *http.Request) {
 }
 
 func listEvents(respWriter http.ResponseWriter, req *http.Request) {
+	store, err := storeOf(req)
+	if err != nil {
+		writeError(respWriter, err)
+		return
+	}
 	startTime := time.Now().Add(-time.Hour)
 	query := req.URL.Query()
 	startTimeStr := query.Get("startTime")
-	var err error
 	if startTimeStr != "" {
-		startTime, err = time.ParseInLocation("201701010800", startTimeStr, evtstore.CST)
+		startTime, err = parseTime(startTimeStr, store.Config.Location)
 		if err != nil {
 			writeError(respWriter, err)
 			return
@@ -56,7 +114,7 @@ func listEvents(respWriter http.ResponseWriter, req *http.Request) {
 	endTime := time.Now()
 	endTimeStr := query.Get("endTime")
 	if endTimeStr != "" {
-		endTime, err = time.ParseInLocation("201701010800", endTimeStr, evtstore.CST)
+		endTime, err = parseTime(endTimeStr, store.Config.Location)
 		if err != nil {
 			writeError(respWriter, err)
 			return
@@ -80,17 +138,221 @@ func listEvents(respWriter http.ResponseWriter, req *http.Request) {
 			return
 		}
 	}
-	blocks, err := store.List(startTime, endTime, skip, limit)
+	var cursor evtstore.Cursor
+	cursorStr := query.Get("cursor")
+	if cursorStr != "" {
+		cursorBytes, err := base64.RawURLEncoding.DecodeString(cursorStr)
+		if err != nil {
+			writeError(respWriter, err)
+			return
+		}
+		cursor, err = evtstore.ParseCursor(cursorBytes)
+		if err != nil {
+			writeError(respWriter, err)
+			return
+		}
+	}
+	blocks, nextCursor, err := store.ListAfter(cursor, startTime, endTime, skip, limit)
 	if err != nil {
 		writeError(respWriter, err)
 		return
 	}
+	// pass it back as cursor to get next page
+	if nextCursor != nil {
+		respWriter.Header().Set("X-Next-Cursor", base64.RawURLEncoding.EncodeToString(nextCursor))
+	}
 	_, err = respWriter.Write(blocks)
 	if err != nil {
 		countlog.Error("event!failed to write blocks", "err", err)
 	}
 }
 
+// parseTime accepts RFC3339, unix milliseconds, or 200601021504 in the time zone of store
+func parseTime(str string, location *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, str); err == nil {
+		return t, nil
+	}
+	if len(str) == len("200601021504") {
+		if location == nil {
+			location = time.UTC
+		}
+		return time.ParseInLocation("200601021504", str, location)
+	}
+	ms, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %s, expect RFC3339 or unix milliseconds", str)
+	}
+	return time.Unix(0, ms*int64(time.Millisecond)), nil
+}
+
+// getBlock responds the block in same binary format as list-events,
+// or the decoded events in json if format=events
+func getBlock(respWriter http.ResponseWriter, req *http.Request) {
+	store, err := storeOf(req)
+	if err != nil {
+		writeError(respWriter, err)
+		return
+	}
+	query := req.URL.Query()
+	offset, err := strconv.ParseUint(query.Get("offset"), 10, 64)
+	if err != nil {
+		writeError(respWriter, err)
+		return
+	}
+	blockId := evtstore.NewEventBlockId(query.Get("fileName"), offset)
+	if query.Get("format") == "events" {
+		iter, err := store.GetBlockEvents(blockId)
+		if err != nil {
+			writeError(respWriter, err)
+			return
+		}
+		events := []map[string]interface{}{}
+		for iter.HasNext() {
+			event := iter.Next()
+			events = append(events, map[string]interface{}{
+				"timestamp": event.Timestamp.Format(time.RFC3339Nano),
+				"body":      string(event.Body),
+			})
+		}
+		resp, err := jsoniter.Marshal(map[string]interface{}{
+			"errno":  0,
+			"events": events,
+		})
+		if err != nil {
+			writeError(respWriter, err)
+			return
+		}
+		respWriter.Write(resp)
+		return
+	}
+	block, err := store.GetBlock(blockId)
+	if err != nil {
+		writeError(respWriter, err)
+		return
+	}
+	_, err = respWriter.Write(append(blockId, block...))
+	if err != nil {
+		countlog.Error("event!failed to write block", "err", err)
+	}
+}
+
+func verifyEvents(respWriter http.ResponseWriter, req *http.Request) {
+	store, err := storeOf(req)
+	if err != nil {
+		writeError(respWriter, err)
+		return
+	}
+	corruptedBlocks, err := store.Verify()
+	if err != nil {
+		writeError(respWriter, err)
+		return
+	}
+	resp, err := jsoniter.Marshal(map[string]interface{}{
+		"errno":           0,
+		"corruptedBlocks": corruptedBlocks,
+	})
+	if err != nil {
+		writeError(respWriter, err)
+		return
+	}
+	respWriter.Write(resp)
+}
+
+// storeStats tells the producers how many events are lost by the overflow of input queue
+func storeStats(respWriter http.ResponseWriter, req *http.Request) {
+	store, err := storeOf(req)
+	if err != nil {
+		writeError(respWriter, err)
+		return
+	}
+	resp, err := jsoniter.Marshal(map[string]interface{}{
+		"errno":           0,
+		"droppedCounters": store.DroppedCounters(),
+	})
+	if err != nil {
+		writeError(respWriter, err)
+		return
+	}
+	respWriter.Write(resp)
+}
+
+// listSceneStats responds the scene counts of the windows in the time range (the last hour by default),
+// optionally of the sessionType and the scenes having all the values in the json of scene parameter
+func listSceneStats(respWriter http.ResponseWriter, req *http.Request) {
+	store, err := storeOf(req)
+	if err != nil {
+		writeError(respWriter, err)
+		return
+	}
+	startTime := time.Now().Add(-time.Hour)
+	query := req.URL.Query()
+	startTimeStr := query.Get("startTime")
+	if startTimeStr != "" {
+		startTime, err = parseTime(startTimeStr, store.Config.Location)
+		if err != nil {
+			writeError(respWriter, err)
+			return
+		}
+	}
+	endTime := time.Now()
+	endTimeStr := query.Get("endTime")
+	if endTimeStr != "" {
+		endTime, err = parseTime(endTimeStr, store.Config.Location)
+		if err != nil {
+			writeError(respWriter, err)
+			return
+		}
+	}
+	sessionType := query.Get("sessionType")
+	var scene map[string]string
+	sceneStr := query.Get("scene")
+	if sceneStr != "" {
+		err = jsoniter.Unmarshal([]byte(sceneStr), &scene)
+		if err != nil {
+			writeError(respWriter, err)
+			return
+		}
+	}
+	stats, err := store.SceneStats(startTime, endTime)
+	if err != nil {
+		writeError(respWriter, err)
+		return
+	}
+	matched := []evtstore.SceneStats{}
+	seen, kept := 0, 0
+	for _, stat := range stats {
+		if sessionType != "" && stat.SessionType != sessionType {
+			continue
+		}
+		if !sceneHasValues(stat.Scene, scene) {
+			continue
+		}
+		matched = append(matched, stat)
+		seen += stat.Seen
+		kept += stat.Kept
+	}
+	resp, err := jsoniter.Marshal(map[string]interface{}{
+		"errno":      0,
+		"sceneStats": matched,
+		"seen":       seen,
+		"kept":       kept,
+	})
+	if err != nil {
+		writeError(respWriter, err)
+		return
+	}
+	respWriter.Write(resp)
+}
+
+func sceneHasValues(scene map[string]string, values map[string]string) bool {
+	for key, value := range values {
+		if scene[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
 func updateSessionMatcher(respWriter http.ResponseWriter, req *http.Request) {
 	var cnf discr.SessionMatcherCnf
 	decoder := jsoniter.NewDecoder(req.Body)
@@ -100,7 +362,7 @@ func updateSessionMatcher(respWriter http.ResponseWriter, req *http.Request) {
 		writeError(respWriter, err)
 		return
 	}
-	err = discr.UpdateSessionMatcher(cnf)
+	err = updateAndSaveSessionMatcher(cnf)
 	if err != nil {
 		writeError(respWriter, err)
 		return
@@ -108,21 +370,57 @@ func updateSessionMatcher(respWriter http.ResponseWriter, req *http.Request) {
 	respWriter.Write([]byte(`{"errno":0}`))
 }
 
+func listSessionMatchers(respWriter http.ResponseWriter, req *http.Request) {
+	resp, err := jsoniter.Marshal(map[string]interface{}{
+		"errno":           0,
+		"sessionMatchers": discr.SessionMatcherCnfs(),
+	})
+	if err != nil {
+		writeError(respWriter, err)
+		return
+	}
+	respWriter.Write(resp)
+}
+
+// deleteSessionMatcher removes the matcher of sessionType= parameter
+func deleteSessionMatcher(respWriter http.ResponseWriter, req *http.Request) {
+	err := req.ParseForm()
+	if err != nil {
+		writeError(respWriter, err)
+		return
+	}
+	err = deleteAndSaveSessionMatcher(req.Form.Get("sessionType"))
+	if err != nil {
+		writeError(respWriter, err)
+		return
+	}
+	respWriter.Write([]byte(`{"errno":0}`))
+}
+
+// tail streams the sessions in html for the form of showTailForm, or in json by sse or websocket
 func tail(respWriter http.ResponseWriter, req *http.Request) {
+	switch tailMode(req) {
+	case "sse":
+		tailSSE(respWriter, req)
+		return
+	case "websocket":
+		tailWebSocket(respWriter, req)
+		return
+	}
 	respWriter.Write([]byte("<html><body>"))
 	err := req.ParseForm()
 	if err != nil {
-		respWriter.Write([]byte(err.Error()))
+		respWriter.Write([]byte(html.EscapeString(err.Error())))
 		return
 	}
 	sessionType := req.Form.Get("sessionType")
-	respWriter.Write([]byte("sessionType: " + sessionType + "<br/>"))
+	respWriter.Write([]byte("sessionType: " + html.EscapeString(sessionType) + "<br/>"))
 	showSession := req.Form.Get("showSession")
-	respWriter.Write([]byte("showSession: " + showSession + "<br/>"))
+	respWriter.Write([]byte("showSession: " + html.EscapeString(showSession) + "<br/>"))
 	limitStr := req.Form.Get("limit")
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil {
-		respWriter.Write([]byte(err.Error()))
+		respWriter.Write([]byte(html.EscapeString(err.Error())))
 		return
 	}
 	respWriter.Write([]byte("limit: " + limitStr + "<br/>"))
@@ -130,14 +428,24 @@ func tail(respWriter http.ResponseWriter, req *http.Request) {
 	matcherCnf := discr.SessionMatcherCnf{}
 	err = jsoniter.Unmarshal([]byte(matcher), &matcherCnf)
 	if err != nil {
-		respWriter.Write([]byte(err.Error()))
+		respWriter.Write([]byte(html.EscapeString(err.Error())))
 		return
 	}
-	respWriter.Write([]byte("matcher: <pre>" + matcher + "</pre><br/>"))
+	respWriter.Write([]byte("matcher: <pre>" + html.EscapeString(matcher) + "</pre><br/>"))
+	filter := req.Form.Get("filter")
+	filterCnf := discr.SceneFilterCnf{}
+	if filter != "" {
+		err = jsoniter.Unmarshal([]byte(filter), &filterCnf)
+		if err != nil {
+			respWriter.Write([]byte(html.EscapeString(err.Error())))
+			return
+		}
+		respWriter.Write([]byte("filter: <pre>" + html.EscapeString(filter) + "</pre><br/>"))
+	}
 	if f, ok := respWriter.(http.Flusher); ok {
 		f.Flush()
 	}
-	discr.Tail(respWriter, sessionType, showSession == "on", limit, matcherCnf)
+	discr.Tail(respWriter, sessionType, showSession == "on", limit, matcherCnf, filterCnf)
 }
 
 func showTailForm(respWriter http.ResponseWriter, req *http.Request) {
@@ -160,6 +468,14 @@ func showTailForm(respWriter http.ResponseWriter, req *http.Request) {
 		}
 	]
 }
+</textarea><br/>
+			Filter:
+<textarea rows="8" cols="60" name="filter">
+{
+	"SceneEquals": {},
+	"SceneMatched": [],
+	"SessionPatterns": {}
+}
 </textarea><br/>
 		<button>tail</button>
 	</form>
@@ -182,4 +498,3 @@ func writeError(respWriter http.ResponseWriter, err error) {
 		return
 	}
 }
-
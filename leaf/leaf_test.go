@@ -0,0 +1,54 @@
+package leaf
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+	"github.com/v2pro/quoll/evtstore"
+	"github.com/blang/vfs/memfs"
+	"github.com/v2pro/quoll/discr"
+)
+
+func Test_add_event_timestamp(t *testing.T) {
+	should := require.New(t)
+	store := addGrpcTestStore(should, "add-event-timestamp")
+	defer store.Close(context.Background())
+	callAddEvent := func(url string) addEventsResp {
+		req := httptest.NewRequest("POST", url, strings.NewReader(`{"url":"/hello"}`))
+		recorder := httptest.NewRecorder()
+		addEvent(recorder, req)
+		var resp addEventsResp
+		should.Nil(jsoniter.Unmarshal(recorder.Body.Bytes(), &resp))
+		return resp
+	}
+	resp := callAddEvent("/add-event?store=add-event-timestamp&timestamp=-1000")
+	should.Equal(1, resp.Errno)
+	should.Equal(evtstore.ErrExpiredEvent.Error(), resp.Errmsg)
+	resp = callAddEvent("/add-event?store=add-event-timestamp&timestamp=2017-01-01T00:00:00Z")
+	should.Equal(0, resp.Errno)
+}
+
+func Test_add_event_overflow(t *testing.T) {
+	should := require.New(t)
+	// not started, so the full queue is never drained
+	store := evtstore.NewStore("/add-event-overflow", evtstore.WithFilesystem(memfs.Create()))
+	store.Config.OverflowPolicy = evtstore.OverflowBlock
+	should.Nil(AddStore("add-event-overflow", store))
+	defer delete(stores, "add-event-overflow")
+	eventBodies := make([]discr.EventBody, store.Config.InputQueueCapacity)
+	for i := range eventBodies {
+		eventBodies[i] = []byte(`{"url":"/hello"}`)
+	}
+	should.Nil(store.AddAll(eventBodies))
+	req := httptest.NewRequest("POST", "/add-event?store=add-event-overflow", strings.NewReader(`{"url":"/hello"}`))
+	recorder := httptest.NewRecorder()
+	addEvent(recorder, req)
+	var resp addEventsResp
+	should.Nil(jsoniter.Unmarshal(recorder.Body.Bytes(), &resp))
+	// the client backs off and retries
+	should.Equal(evtstore.ErrInputQueueOverflow.Error(), resp.Errmsg)
+	should.Equal(uint64(1), store.DroppedCounters().TimedOut)
+}
@@ -0,0 +1,5 @@
+// Package leafpb is the grpc contract of leaf defined by leaf.proto, for the clients in other languages
+package leafpb
+
+// leaf.pb.go is generated by protoc-gen-go of github.com/golang/protobuf v1.5, the last one supporting plugins=grpc
+//go:generate protoc --go_out=plugins=grpc,paths=source_relative:. leaf.proto
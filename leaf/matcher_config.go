@@ -0,0 +1,140 @@
+package leaf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"time"
+	"github.com/json-iterator/go"
+	"github.com/v2pro/plz/countlog"
+	"github.com/v2pro/quoll/discr"
+	"gopkg.in/yaml.v2"
+)
+
+// LoadSessionMatchersConfig replaces all the session matchers by the list of SessionMatcherCnf in the file,
+// in yaml if named *.yaml or *.yml, otherwise in json. The fields are named as SessionMatcherCnf.
+// Nothing is applied if any entry is rejected, the error is discr.SessionMatcherCnfErrors then.
+// The matchers updated by the api are replaced as well, the file is the full set.
+// The applied content is returned for WatchSessionMatchersConfig.
+func LoadSessionMatchersConfig(filePath string) ([]byte, error) {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	err = applySessionMatchersConfig(filePath, content)
+	if err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// WatchSessionMatchersConfig reloads the file when its content differs from the applied one, until the context is done.
+// The file is polled, so that it can be replaced by rename as well as edited in place.
+func WatchSessionMatchersConfig(ctx context.Context, filePath string, appliedContent []byte, interval time.Duration) {
+	lastContent := appliedContent
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		lastContent = reloadSessionMatchersConfig(filePath, lastContent)
+	}
+}
+
+// reloadSessionMatchersConfig applies the file if changed, the current matchers are kept if rejected.
+// The content read is returned, so that the rejected content is not tried again until changed.
+func reloadSessionMatchersConfig(filePath string, lastContent []byte) []byte {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		countlog.Error("event!agent.failed to read session matchers config", "err", err, "filePath", filePath)
+		return lastContent
+	}
+	if bytes.Equal(content, lastContent) {
+		return lastContent
+	}
+	err = applySessionMatchersConfig(filePath, content)
+	if err != nil {
+		logSessionMatchersConfigError(filePath, err)
+		return content
+	}
+	countlog.Info("event!agent.reloaded_session_matchers_config", "filePath", filePath)
+	return content
+}
+
+func logSessionMatchersConfigError(filePath string, err error) {
+	errs, ok := err.(discr.SessionMatcherCnfErrors)
+	if !ok {
+		countlog.Error("event!agent.failed to load session matchers config", "err", err, "filePath", filePath)
+		return
+	}
+	for _, entryErr := range errs {
+		countlog.Error("event!agent.rejected session matcher in config", "err", entryErr.Err,
+			"filePath", filePath, "index", entryErr.Index, "sessionType", entryErr.SessionType)
+	}
+}
+
+func applySessionMatchersConfig(filePath string, content []byte) error {
+	cnfs, err := parseSessionMatchersConfig(filePath, content)
+	if err != nil {
+		return err
+	}
+	sessionMatchersMutex.Lock()
+	defer sessionMatchersMutex.Unlock()
+	err = discr.ReplaceSessionMatchers(cnfs)
+	if err != nil {
+		return err
+	}
+	// the file is the full set
+	rejectedSessionMatchers = nil
+	return saveSessionMatchers()
+}
+
+func parseSessionMatchersConfig(filePath string, content []byte) ([]discr.SessionMatcherCnf, error) {
+	var cnfs []discr.SessionMatcherCnf
+	err := unmarshalConfig(filePath, content, &cnfs)
+	if err != nil {
+		return nil, err
+	}
+	return cnfs, nil
+}
+
+// unmarshalConfig reads yaml if the file is named *.yaml or *.yml, otherwise json
+func unmarshalConfig(filePath string, content []byte, obj interface{}) error {
+	switch path.Ext(filePath) {
+	case ".yaml", ".yml":
+		var parsed interface{}
+		err := yaml.Unmarshal(content, &parsed)
+		if err != nil {
+			return err
+		}
+		// convert to json, so that the fields are named the same way in both formats
+		content, err = jsoniter.Marshal(jsonCompatible(parsed))
+		if err != nil {
+			return err
+		}
+	}
+	return jsoniter.Unmarshal(content, obj)
+}
+
+// jsonCompatible converts the map[interface{}]interface{} decoded by yaml to map[string]interface{}
+func jsonCompatible(obj interface{}) interface{} {
+	switch typed := obj.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(typed))
+		for key, value := range typed {
+			converted[fmt.Sprint(key)] = jsonCompatible(value)
+		}
+		return converted
+	case []interface{}:
+		for i, elem := range typed {
+			typed[i] = jsonCompatible(elem)
+		}
+		return typed
+	}
+	return obj
+}
@@ -0,0 +1,95 @@
+package leaf
+
+import (
+	"io/ioutil"
+	"path"
+	"testing"
+	"github.com/stretchr/testify/require"
+	"github.com/v2pro/quoll/discr"
+)
+
+func Test_parse_session_matchers_config(t *testing.T) {
+	should := require.New(t)
+	cases := []struct {
+		filePath string
+		content  string
+		cnfs     []discr.SessionMatcherCnf
+		hasErr   bool
+	}{
+		{"matchers.json", `[{"SessionType":"/hello","KeepNSessionsPerScene":2}]`,
+			[]discr.SessionMatcherCnf{{SessionType: "/hello", KeepNSessionsPerScene: 2}}, false},
+		{"matchers.yaml", "- SessionType: /hello\n  KeepNSessionsPerScene: 2\n",
+			[]discr.SessionMatcherCnf{{SessionType: "/hello", KeepNSessionsPerScene: 2}}, false},
+		{"matchers.yml", "- SessionType: /hello\n  InboundRequestPatterns:\n    product_id: 'id=(\\d+)'\n",
+			[]discr.SessionMatcherCnf{{SessionType: "/hello",
+				InboundRequestPatterns: map[string]string{"product_id": `id=(\d+)`}}}, false},
+		{"matchers.yaml", "- SessionType: /hello\n  Sampling:\n    Strategy: probability\n    Probability: 0.5\n",
+			[]discr.SessionMatcherCnf{{SessionType: "/hello",
+				Sampling: discr.SamplingCnf{Strategy: "probability", Probability: 0.5}}}, false},
+		// json is yaml as well
+		{"matchers.yaml", `[{"SessionType":"/hello"}]`,
+			[]discr.SessionMatcherCnf{{SessionType: "/hello"}}, false},
+		{"matchers.yaml", "- SessionType: [", nil, true},
+		{"matchers.json", "- SessionType: /hello", nil, true},
+		{"matchers.yaml", "SessionType: /hello", nil, true},
+	}
+	for _, c := range cases {
+		cnfs, err := parseSessionMatchersConfig(c.filePath, []byte(c.content))
+		if c.hasErr {
+			should.NotNil(err, c.content)
+			continue
+		}
+		should.Nil(err, c.content)
+		should.Equal(c.cnfs, cnfs, c.content)
+	}
+}
+
+func Test_json_compatible(t *testing.T) {
+	should := require.New(t)
+	cases := []struct {
+		input    interface{}
+		expected interface{}
+	}{
+		{"hello", "hello"},
+		{map[interface{}]interface{}{1: "a", "b": true},
+			map[string]interface{}{"1": "a", "b": true}},
+		{[]interface{}{map[interface{}]interface{}{"a": []interface{}{map[interface{}]interface{}{2: 3}}}},
+			[]interface{}{map[string]interface{}{"a": []interface{}{map[string]interface{}{"2": 3}}}}},
+	}
+	for _, c := range cases {
+		should.Equal(c.expected, jsonCompatible(c.input))
+	}
+}
+
+func Test_reload_session_matchers_config(t *testing.T) {
+	should := require.New(t)
+	savedFilePath, restore := useTempSessionMatchersFile(should)
+	defer restore()
+	filePath := path.Join(path.Dir(savedFilePath), "matchers.yaml")
+	should.Nil(ioutil.WriteFile(filePath, []byte("- SessionType: /v1\n"), 0666))
+	appliedContent, err := LoadSessionMatchersConfig(filePath)
+	should.Nil(err)
+	should.Equal("- SessionType: /v1\n", string(appliedContent))
+	// changed after loaded, before watched
+	should.Nil(ioutil.WriteFile(filePath, []byte("- SessionType: /v2\n"), 0666))
+	lastContent := reloadSessionMatchersConfig(filePath, appliedContent)
+	should.Equal("- SessionType: /v2\n", string(lastContent))
+	should.Equal([]string{"/v2"}, listedSessionTypes(should))
+	// the rejected config keeps the current matchers
+	rejected := "- SessionType: /v3\n- SessionType: /v4\n  Sampling:\n    Strategy: unknown\n"
+	should.Nil(ioutil.WriteFile(filePath, []byte(rejected), 0666))
+	lastContent = reloadSessionMatchersConfig(filePath, lastContent)
+	should.Equal(rejected, string(lastContent))
+	should.Equal([]string{"/v2"}, listedSessionTypes(should))
+	// the unreadable file keeps the current matchers
+	should.Nil(ioutil.WriteFile(filePath, []byte("- SessionType: ["), 0666))
+	reloadSessionMatchersConfig(filePath, lastContent)
+	should.Equal([]string{"/v2"}, listedSessionTypes(should))
+	should.Nil(ioutil.WriteFile(filePath, []byte("- SessionType: /v3\n- SessionType: /v4\n"), 0666))
+	reloadSessionMatchersConfig(filePath, lastContent)
+	should.Equal([]string{"/v3", "/v4"}, listedSessionTypes(should))
+	// the applied config is saved for restart
+	content, err := ioutil.ReadFile(savedFilePath)
+	should.Nil(err)
+	should.Contains(string(content), `"/v4"`)
+}
@@ -0,0 +1,155 @@
+package leaf
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"github.com/json-iterator/go"
+	"github.com/v2pro/plz/countlog"
+	"github.com/v2pro/quoll/discr"
+)
+
+// sessionMatchersFileName is kept in the root dir of default store, unless SetSessionMatchersFile
+const sessionMatchersFileName = "session_matchers.json"
+
+var sessionMatchersFile string
+
+// sessionMatchersMutex keeps the saved file in the order of updates
+var sessionMatchersMutex = &sync.Mutex{}
+
+// rejectedSessionMatchers are the entries of the loaded file which can not be applied, such as written by
+// a newer version. They are saved back as they are, instead of being lost by next update,
+// until the session type is updated or deleted by the api, or replaced by the config file.
+var rejectedSessionMatchers []rejectedSessionMatcher
+
+type rejectedSessionMatcher struct {
+	sessionType string
+	raw         jsoniter.RawMessage
+}
+
+// SetSessionMatchersFile changes where the session matchers are persisted, must be called before RegisterHttpHandlers
+func SetSessionMatchersFile(filePath string) {
+	sessionMatchersFile = filePath
+}
+
+// sessionMatchersFilePath is empty if neither SetSessionMatchersFile nor the default store
+func sessionMatchersFilePath() string {
+	if sessionMatchersFile != "" {
+		return sessionMatchersFile
+	}
+	defaultStore := stores[DefaultStoreName]
+	if defaultStore == nil {
+		return ""
+	}
+	return path.Join(defaultStore.RootDir, sessionMatchersFileName)
+}
+
+// loadSessionMatchers restores the matchers saved before restart. The invalid matcher is skipped and kept in
+// rejectedSessionMatchers, while the unreadable file fails the startup, instead of being overwritten by next update.
+func loadSessionMatchers() error {
+	filePath := sessionMatchersFilePath()
+	content, err := ioutil.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var entries []jsoniter.RawMessage
+	err = jsoniter.Unmarshal(content, &entries)
+	if err != nil {
+		return err
+	}
+	sessionMatchersMutex.Lock()
+	defer sessionMatchersMutex.Unlock()
+	rejectedSessionMatchers = nil
+	for _, entry := range entries {
+		var cnf discr.SessionMatcherCnf
+		err = jsoniter.Unmarshal(entry, &cnf)
+		if err == nil {
+			err = discr.UpdateSessionMatcher(cnf)
+		}
+		if err != nil {
+			countlog.Error("event!agent.failed to load session matcher, kept in file", "err", err,
+				"sessionType", cnf.SessionType)
+			rejectedSessionMatchers = append(rejectedSessionMatchers, rejectedSessionMatcher{
+				sessionType: cnf.SessionType,
+				raw:         append(jsoniter.RawMessage(nil), entry...),
+			})
+		}
+	}
+	countlog.Info("event!agent.loaded_session_matchers", "filePath", filePath,
+		"count", len(entries), "rejectedCount", len(rejectedSessionMatchers))
+	return nil
+}
+
+// forgetRejectedSessionMatcher is called when the session type is updated or deleted by the api,
+// true if there was a rejected entry of the session type
+func forgetRejectedSessionMatcher(sessionType string) bool {
+	kept := rejectedSessionMatchers[:0]
+	for _, rejected := range rejectedSessionMatchers {
+		if rejected.sessionType != sessionType {
+			kept = append(kept, rejected)
+		}
+	}
+	forgotten := len(kept) < len(rejectedSessionMatchers)
+	rejectedSessionMatchers = kept
+	return forgotten
+}
+
+// saveSessionMatchers writes to a temp file then renames, so the file is not torn by crash.
+// The rejected entries are written after the applied ones.
+func saveSessionMatchers() error {
+	entries := []jsoniter.RawMessage{}
+	for _, cnf := range discr.SessionMatcherCnfs() {
+		entry, err := jsoniter.Marshal(cnf)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+	}
+	for _, rejected := range rejectedSessionMatchers {
+		entries = append(entries, rejected.raw)
+	}
+	content, err := jsoniter.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	filePath := sessionMatchersFilePath()
+	err = os.MkdirAll(path.Dir(filePath), 0777)
+	if err != nil {
+		return err
+	}
+	tmpFilePath := filePath + ".tmp"
+	err = ioutil.WriteFile(tmpFilePath, content, 0666)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpFilePath, filePath)
+}
+
+// updateAndSaveSessionMatcher is shared by the http and grpc api
+func updateAndSaveSessionMatcher(cnf discr.SessionMatcherCnf) error {
+	sessionMatchersMutex.Lock()
+	defer sessionMatchersMutex.Unlock()
+	err := discr.UpdateSessionMatcher(cnf)
+	if err != nil {
+		return err
+	}
+	forgetRejectedSessionMatcher(cnf.SessionType)
+	return saveSessionMatchers()
+}
+
+func deleteAndSaveSessionMatcher(sessionType string) error {
+	sessionMatchersMutex.Lock()
+	defer sessionMatchersMutex.Unlock()
+	err := discr.DeleteSessionMatcher(sessionType)
+	if forgetRejectedSessionMatcher(sessionType) {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+	return saveSessionMatchers()
+}
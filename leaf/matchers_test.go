@@ -0,0 +1,119 @@
+package leaf
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+	"github.com/v2pro/quoll/discr"
+)
+
+// useTempSessionMatchersFile starts without any session matcher, the returned func restores
+func useTempSessionMatchersFile(should *require.Assertions) (string, func()) {
+	dir, err := ioutil.TempDir("", "leaf")
+	should.Nil(err)
+	filePath := path.Join(dir, sessionMatchersFileName)
+	SetSessionMatchersFile(filePath)
+	should.Nil(discr.ReplaceSessionMatchers(nil))
+	return filePath, func() {
+		SetSessionMatchersFile("")
+		discr.ReplaceSessionMatchers(nil)
+		rejectedSessionMatchers = nil
+		os.RemoveAll(dir)
+	}
+}
+
+func callSessionMatchersApi(should *require.Assertions, handler http.HandlerFunc,
+	method string, url string, body string) map[string]interface{} {
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	if method == "POST" {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	recorder := httptest.NewRecorder()
+	handler(recorder, req)
+	var resp map[string]interface{}
+	should.Nil(jsoniter.Unmarshal(recorder.Body.Bytes(), &resp))
+	return resp
+}
+
+func listedSessionTypes(should *require.Assertions) []string {
+	resp := callSessionMatchersApi(should, listSessionMatchers, "GET", "/list-session-matchers", "")
+	should.Equal(float64(0), resp["errno"])
+	sessionTypes := []string{}
+	for _, cnf := range resp["sessionMatchers"].([]interface{}) {
+		sessionTypes = append(sessionTypes, cnf.(map[string]interface{})["SessionType"].(string))
+	}
+	return sessionTypes
+}
+
+func Test_session_matchers_saved_and_loaded(t *testing.T) {
+	should := require.New(t)
+	filePath, restore := useTempSessionMatchersFile(should)
+	defer restore()
+	// not saved yet
+	should.Nil(loadSessionMatchers())
+	should.Equal([]string{}, listedSessionTypes(should))
+	resp := callSessionMatchersApi(should, updateSessionMatcher, "POST", "/update-session-matcher",
+		`{"SessionType":"/hello","KeepNSessionsPerScene":3}`)
+	should.Equal(float64(0), resp["errno"])
+	// restarted
+	should.Nil(discr.ReplaceSessionMatchers(nil))
+	should.Nil(loadSessionMatchers())
+	cnfs := discr.SessionMatcherCnfs()
+	should.Len(cnfs, 1)
+	should.Equal("/hello", cnfs[0].SessionType)
+	should.Equal(3, cnfs[0].KeepNSessionsPerScene)
+	resp = callSessionMatchersApi(should, deleteSessionMatcher, "POST", "/delete-session-matcher",
+		"sessionType=/hello")
+	should.Equal(float64(0), resp["errno"])
+	should.Equal([]string{}, listedSessionTypes(should))
+	resp = callSessionMatchersApi(should, deleteSessionMatcher, "POST", "/delete-session-matcher",
+		"sessionType=/hello")
+	should.Equal(float64(1), resp["errno"])
+	content, err := ioutil.ReadFile(filePath)
+	should.Nil(err)
+	should.Equal("[]", string(content))
+}
+
+func Test_session_matchers_keep_rejected_in_file(t *testing.T) {
+	should := require.New(t)
+	filePath, restore := useTempSessionMatchersFile(should)
+	defer restore()
+	should.Nil(ioutil.WriteFile(filePath, []byte(`[
+		{"SessionType":"/valid"},
+		{"SessionType":"/newer","Sampling":{"Strategy":"newer-strategy"}}]`), 0666))
+	should.Nil(loadSessionMatchers())
+	should.Equal([]string{"/valid"}, listedSessionTypes(should))
+	resp := callSessionMatchersApi(should, updateSessionMatcher, "POST", "/update-session-matcher",
+		`{"SessionType":"/added"}`)
+	should.Equal(float64(0), resp["errno"])
+	// the rejected entry is not lost by the update
+	content, err := ioutil.ReadFile(filePath)
+	should.Nil(err)
+	should.Contains(string(content), `"/added"`)
+	should.Contains(string(content), `"newer-strategy"`)
+	should.Nil(discr.ReplaceSessionMatchers(nil))
+	should.Nil(loadSessionMatchers())
+	should.Equal([]string{"/added", "/valid"}, listedSessionTypes(should))
+	// the rejected entry can be deleted by the api
+	resp = callSessionMatchersApi(should, deleteSessionMatcher, "POST", "/delete-session-matcher",
+		"sessionType=/newer")
+	should.Equal(float64(0), resp["errno"])
+	content, err = ioutil.ReadFile(filePath)
+	should.Nil(err)
+	should.NotContains(string(content), `"newer-strategy"`)
+	should.Contains(string(content), `"/valid"`)
+}
+
+func Test_session_matchers_unreadable_file(t *testing.T) {
+	should := require.New(t)
+	filePath, restore := useTempSessionMatchersFile(should)
+	defer restore()
+	should.Nil(ioutil.WriteFile(filePath, []byte(`{"SessionType":`), 0666))
+	should.NotNil(loadSessionMatchers())
+}
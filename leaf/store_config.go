@@ -0,0 +1,132 @@
+package leaf
+
+import (
+	"errors"
+	"io/ioutil"
+	"time"
+	"github.com/v2pro/quoll/evtstore"
+)
+
+// StoreCnf configures a store hosted by leaf, the zero fields take the default of evtstore.
+// The durations are written like 90s or 24h, and the policies by name. The config is validated by Store.Start.
+type StoreCnf struct {
+	Name                 string
+	RootDir              string
+	RotationInterval     string
+	MaximumFlushInterval string
+	KeepFilesCount       int
+	MaxTotalBytes        int64
+	MaxAge               string
+	MinFreeDiskBytes     int64
+	// Location is the time zone name, such as Asia/Shanghai
+	Location           string
+	InputQueueCapacity int
+	// OverflowPolicy is block (default), reject or drop-oldest
+	OverflowPolicy string
+	// SyncPolicy is never (default), every-block, periodically or on-rotation
+	SyncPolicy         string
+	SyncInterval       string
+	SceneStatsInterval string
+}
+
+var overflowPolicies = map[string]evtstore.OverflowPolicy{
+	"block":       evtstore.OverflowBlock,
+	"reject":      evtstore.OverflowReject,
+	"drop-oldest": evtstore.OverflowDropOldest,
+}
+
+var syncPolicies = map[string]evtstore.SyncPolicy{
+	"never":        evtstore.SyncNever,
+	"every-block":  evtstore.SyncEveryBlock,
+	"periodically": evtstore.SyncPeriodically,
+	"on-rotation":  evtstore.SyncOnRotation,
+}
+
+// LoadStoresConfig adds the stores of the list of StoreCnf in the file, in yaml if named *.yaml or *.yml,
+// otherwise in json. Nothing is added if any entry is invalid. Must be called before RegisterHttpHandlers.
+func LoadStoresConfig(filePath string) error {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	var cnfs []StoreCnf
+	err = unmarshalConfig(filePath, content, &cnfs)
+	if err != nil {
+		return err
+	}
+	newStores := make([]*evtstore.Store, len(cnfs))
+	for i, cnf := range cnfs {
+		newStores[i], err = cnf.newStore()
+		if err != nil {
+			return errors.New("store " + cnf.Name + ": " + err.Error())
+		}
+	}
+	for i, cnf := range cnfs {
+		err = AddStore(cnf.Name, newStores[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cnf StoreCnf) newStore() (*evtstore.Store, error) {
+	if cnf.Name == "" {
+		return nil, errors.New("store name is empty")
+	}
+	if cnf.RootDir == "" {
+		return nil, errors.New("root dir is empty")
+	}
+	store := newLeafStore(cnf.RootDir)
+	config := &store.Config
+	durations := []struct {
+		value string
+		field *time.Duration
+	}{
+		{cnf.RotationInterval, &config.RotationInterval},
+		{cnf.MaximumFlushInterval, &config.MaximumFlushInterval},
+		{cnf.MaxAge, &config.MaxAge},
+		{cnf.SyncInterval, &config.SyncInterval},
+		{cnf.SceneStatsInterval, &config.SceneStatsInterval},
+	}
+	for _, duration := range durations {
+		if duration.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(duration.value)
+		if err != nil {
+			return nil, err
+		}
+		*duration.field = parsed
+	}
+	if cnf.KeepFilesCount != 0 {
+		config.KeepFilesCount = cnf.KeepFilesCount
+	}
+	config.MaxTotalBytes = cnf.MaxTotalBytes
+	config.MinFreeDiskBytes = cnf.MinFreeDiskBytes
+	if cnf.InputQueueCapacity != 0 {
+		config.InputQueueCapacity = cnf.InputQueueCapacity
+	}
+	if cnf.Location != "" {
+		location, err := time.LoadLocation(cnf.Location)
+		if err != nil {
+			return nil, err
+		}
+		config.Location = location
+	}
+	if cnf.OverflowPolicy != "" {
+		policy, found := overflowPolicies[cnf.OverflowPolicy]
+		if !found {
+			return nil, errors.New("unknown overflow policy: " + cnf.OverflowPolicy)
+		}
+		config.OverflowPolicy = policy
+	}
+	if cnf.SyncPolicy != "" {
+		policy, found := syncPolicies[cnf.SyncPolicy]
+		if !found {
+			return nil, errors.New("unknown sync policy: " + cnf.SyncPolicy)
+		}
+		config.SyncPolicy = policy
+	}
+	return store, nil
+}
@@ -0,0 +1,111 @@
+package leaf
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/require"
+	"github.com/v2pro/quoll/evtstore"
+)
+
+func Test_store_cnf(t *testing.T) {
+	should := require.New(t)
+	store, err := StoreCnf{Name: "minimal", RootDir: "/tmp/minimal"}.newStore()
+	should.Nil(err)
+	should.Equal("/tmp/minimal", store.RootDir)
+	should.Equal(time.Hour, store.Config.RotationInterval)
+	should.Equal(evtstore.OverflowBlock, store.Config.OverflowPolicy)
+	store, err = StoreCnf{
+		Name:               "full",
+		RootDir:            "/tmp/full",
+		RotationInterval:   "24h",
+		KeepFilesCount:     7,
+		MaxAge:             "168h",
+		Location:           "Asia/Shanghai",
+		InputQueueCapacity: 1000,
+		OverflowPolicy:     "drop-oldest",
+		SyncPolicy:         "periodically",
+		SyncInterval:       "5s",
+	}.newStore()
+	should.Nil(err)
+	should.Equal(24*time.Hour, store.Config.RotationInterval)
+	should.Equal(7, store.Config.KeepFilesCount)
+	should.Equal(7*24*time.Hour, store.Config.MaxAge)
+	should.Equal("Asia/Shanghai", store.Config.Location.String())
+	should.Equal(1000, store.Config.InputQueueCapacity)
+	should.Equal(evtstore.OverflowDropOldest, store.Config.OverflowPolicy)
+	should.Equal(evtstore.SyncPeriodically, store.Config.SyncPolicy)
+	should.Equal(5*time.Second, store.Config.SyncInterval)
+	invalidCnfs := []StoreCnf{
+		{RootDir: "/tmp/invalid"},
+		{Name: "invalid"},
+		{Name: "invalid", RootDir: "/tmp/invalid", RotationInterval: "1 hour"},
+		{Name: "invalid", RootDir: "/tmp/invalid", Location: "Nowhere/Nowhere"},
+		{Name: "invalid", RootDir: "/tmp/invalid", OverflowPolicy: "wait"},
+		{Name: "invalid", RootDir: "/tmp/invalid", SyncPolicy: "always"},
+	}
+	for _, cnf := range invalidCnfs {
+		_, err := cnf.newStore()
+		should.NotNil(err, cnf)
+	}
+}
+
+func Test_load_stores_config(t *testing.T) {
+	should := require.New(t)
+	dir, err := ioutil.TempDir("", "leaf")
+	should.Nil(err)
+	defer os.RemoveAll(dir)
+	defer delete(stores, "config-daily")
+	defer delete(stores, "config-hourly")
+	filePath := path.Join(dir, "stores.yaml")
+	should.Nil(ioutil.WriteFile(filePath, []byte(`
+- Name: config-daily
+  RootDir: /tmp/config-daily
+  RotationInterval: 24h
+- Name: config-hourly
+  RootDir: /tmp/config-hourly
+`), 0666))
+	should.Nil(LoadStoresConfig(filePath))
+	should.Equal(24*time.Hour, stores["config-daily"].Config.RotationInterval)
+	should.Equal("/tmp/config-hourly", stores["config-hourly"].RootDir)
+	// nothing is added if any entry is invalid
+	should.Nil(ioutil.WriteFile(filePath, []byte(`[
+		{"Name":"config-invalid1","RootDir":"/tmp/config-invalid1"},
+		{"Name":"config-invalid2","RootDir":"/tmp/config-invalid2","MaxAge":"forever"}]`), 0666))
+	should.NotNil(LoadStoresConfig(filePath))
+	should.Nil(stores["config-invalid1"])
+}
+
+func Test_default_store(t *testing.T) {
+	should := require.New(t)
+	originalRootDir := defaultStoreRootDir
+	originalStore := stores[DefaultStoreName]
+	defer func() {
+		defaultStoreRootDir = originalRootDir
+		stores[DefaultStoreName] = originalStore
+		if originalStore == nil {
+			delete(stores, DefaultStoreName)
+		}
+	}()
+	delete(stores, DefaultStoreName)
+	SetDefaultStoreRootDir("")
+	should.Nil(addDefaultStore())
+	should.Nil(stores[DefaultStoreName])
+	_, err := storeNamed("")
+	should.NotNil(err)
+	should.Equal("", sessionMatchersFilePath())
+	SetDefaultStoreRootDir("/tmp/default-store")
+	should.Nil(addDefaultStore())
+	store, err := storeNamed("")
+	should.Nil(err)
+	should.Equal("/tmp/default-store", store.RootDir)
+	should.Equal("/tmp/default-store/session_matchers.json", sessionMatchersFilePath())
+	// replaced by AddStore
+	should.Nil(AddStore(DefaultStoreName, evtstore.NewStore("/tmp/replaced-store")))
+	should.Nil(addDefaultStore())
+	store, err = storeNamed("")
+	should.Nil(err)
+	should.Equal("/tmp/replaced-store", store.RootDir)
+}
@@ -0,0 +1,93 @@
+package leaf
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"github.com/v2pro/quoll/evtstore"
+)
+
+// DefaultStoreName is used when the request does not specify store=
+const DefaultStoreName = "default"
+
+// stores is only modified before RegisterHttpHandlers, so it is read without lock
+var stores = map[string]*evtstore.Store{}
+
+// defaultStoreRootDir is where the store of DefaultStoreName is created, unless it is added by AddStore
+var defaultStoreRootDir = "/tmp/store"
+
+// SetDefaultStoreRootDir changes where the default store is created, the empty dir to serve without default store.
+// Must be called before RegisterHttpHandlers.
+func SetDefaultStoreRootDir(rootDir string) {
+	defaultStoreRootDir = rootDir
+}
+
+// newLeafStore blocks the add for the full input queue up to addEventTimeout, then fails it with
+// ErrInputQueueOverflow, which the client retries after backing off
+func newLeafStore(rootDir string) *evtstore.Store {
+	store := evtstore.NewStore(rootDir)
+	store.Config.OverflowPolicy = evtstore.OverflowBlock
+	return store
+}
+
+// addDefaultStore creates the default store, unless it is added by AddStore or disabled
+func addDefaultStore() error {
+	if stores[DefaultStoreName] != nil || defaultStoreRootDir == "" {
+		return nil
+	}
+	return AddStore(DefaultStoreName, newLeafStore(defaultStoreRootDir))
+}
+
+// AddStore hosts another store with its own root dir and config, must be called before RegisterHttpHandlers.
+// The store of DefaultStoreName replaces the one created in defaultStoreRootDir.
+func AddStore(name string, store *evtstore.Store) error {
+	if name == "" {
+		return errors.New("store name is empty")
+	}
+	for existingName, existingStore := range stores {
+		if existingName != name && existingStore.RootDir == store.RootDir {
+			return errors.New("root dir " + store.RootDir + " is used by store " + existingName)
+		}
+	}
+	stores[name] = store
+	return nil
+}
+
+func startStores() error {
+	for _, store := range stores {
+		err := store.Start()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// storeOf selects the store by the store= parameter
+func storeOf(req *http.Request) (*evtstore.Store, error) {
+	return storeNamed(req.URL.Query().Get("store"))
+}
+
+// storeNamed selects the store by name, the empty name for the default store
+func storeNamed(name string) (*evtstore.Store, error) {
+	if name == "" {
+		name = DefaultStoreName
+	}
+	store := stores[name]
+	if store == nil {
+		return nil, errors.New("unknown store: " + name)
+	}
+	return store, nil
+}
+
+// Close saves the events still in memory, should be called before the process exits
+func Close(ctx context.Context) error {
+	var firstErr error
+	for _, store := range stores {
+		err := store.Close(ctx)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,203 @@
+package leaf
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"github.com/json-iterator/go"
+	"github.com/v2pro/plz/countlog"
+	"github.com/v2pro/quoll/discr"
+	"golang.org/x/net/websocket"
+	"net/url"
+)
+
+// tailParams are read from the form of /tail, limit 0 is unlimited
+type tailParams struct {
+	filter      discr.SubscribeFilter
+	showSession bool
+	limit       int
+}
+
+func parseTailParams(req *http.Request) (*tailParams, error) {
+	err := req.ParseForm()
+	if err != nil {
+		return nil, err
+	}
+	params := &tailParams{filter: discr.SubscribeFilter{SessionType: req.Form.Get("sessionType")}}
+	showSession := req.Form.Get("showSession")
+	params.showSession = showSession == "on" || showSession == "true"
+	limitStr := req.Form.Get("limit")
+	if limitStr != "" {
+		params.limit, err = strconv.Atoi(limitStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	matcher := req.Form.Get("matcher")
+	if matcher != "" {
+		err = jsoniter.Unmarshal([]byte(matcher), &params.filter.Matcher)
+		if err != nil {
+			return nil, err
+		}
+	}
+	filter := req.Form.Get("filter")
+	if filter != "" {
+		err = jsoniter.Unmarshal([]byte(filter), &params.filter.Where)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return params, nil
+}
+
+// tailMode picks the stream format by mode= parameter, or by the request headers if not specified
+func tailMode(req *http.Request) string {
+	mode := req.URL.Query().Get("mode")
+	if mode != "" {
+		return mode
+	}
+	if strings.EqualFold(req.Header.Get("Upgrade"), "websocket") {
+		return "websocket"
+	}
+	if strings.Contains(req.Header.Get("Accept"), "text/event-stream") {
+		return "sse"
+	}
+	return "html"
+}
+
+// tailedSessionJSON is the payload of sse and websocket mode
+type tailedSessionJSON struct {
+	SessionType string            `json:"sessionType"`
+	Scene       map[string]string `json:"scene,omitempty"`
+	MatchError  string            `json:"matchError,omitempty"`
+	Session     string            `json:"session,omitempty"`
+}
+
+func newTailedSessionJSON(tailed discr.TailedSession, showSession bool) *tailedSessionJSON {
+	obj := &tailedSessionJSON{SessionType: tailed.SessionType}
+	if tailed.MatchErr != nil {
+		obj.MatchError = tailed.MatchErr.Error()
+	} else if tailed.Scene != nil {
+		obj.Scene = tailed.Scene.ToMap()
+	}
+	if showSession {
+		obj.Session = string(tailed.Session)
+	}
+	return obj
+}
+
+// tailEndJSON tells why the stream ends, such as "limit reached"
+type tailEndJSON struct {
+	Reason string `json:"reason"`
+}
+
+// tailSSE streams each session as "session" event, and the reason of end as "end" event
+func tailSSE(respWriter http.ResponseWriter, req *http.Request) {
+	params, err := parseTailParams(req)
+	if err != nil {
+		writeError(respWriter, err)
+		return
+	}
+	flusher, ok := respWriter.(http.Flusher)
+	if !ok {
+		writeError(respWriter, errors.New("streaming is not supported by the connection"))
+		return
+	}
+	respWriter.Header().Set("Content-Type", "text/event-stream")
+	respWriter.Header().Set("Cache-Control", "no-cache")
+	respWriter.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	err = discr.TailSessions(req.Context(), params.filter, params.limit, func(tailed discr.TailedSession) error {
+		err := writeSSE(respWriter, "session", newTailedSessionJSON(tailed, params.showSession))
+		if err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	})
+	if err == context.Canceled {
+		return
+	}
+	if writeErr := writeSSE(respWriter, "end", &tailEndJSON{Reason: err.Error()}); writeErr != nil {
+		countlog.Error("event!tail.err", "err", writeErr)
+		return
+	}
+	flusher.Flush()
+}
+
+func writeSSE(respWriter http.ResponseWriter, event string, obj interface{}) error {
+	data, err := jsoniter.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	// the json is in one line, so it fits in one data field
+	_, err = respWriter.Write([]byte("event: " + event + "\ndata: " + string(data) + "\n\n"))
+	return err
+}
+
+// tailAllowedOrigins are the web origins allowed to tail by websocket, besides the leaf itself
+var tailAllowedOrigins []string
+
+// SetTailAllowedOrigins allows the pages of other origins, such as http://dashboard:8080, to tail by websocket
+func SetTailAllowedOrigins(origins []string) {
+	tailAllowedOrigins = origins
+}
+
+// checkTailOrigin rejects the cross-site websocket, otherwise any page opened by the developer
+// could read the sessions through the browser. The clients other than browsers send no Origin.
+func checkTailOrigin(req *http.Request) error {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	originUrl, err := url.Parse(origin)
+	if err != nil {
+		return err
+	}
+	if originUrl.Host == req.Host {
+		return nil
+	}
+	for _, allowed := range tailAllowedOrigins {
+		if origin == allowed {
+			return nil
+		}
+	}
+	return errors.New("origin not allowed: " + origin)
+}
+
+// tailWebSocket sends each session as json message, and the reason of end before closing
+func tailWebSocket(respWriter http.ResponseWriter, req *http.Request) {
+	params, err := parseTailParams(req)
+	if err != nil {
+		writeError(respWriter, err)
+		return
+	}
+	server := websocket.Server{
+		Handshake: func(config *websocket.Config, req *http.Request) error {
+			return checkTailOrigin(req)
+		},
+		Handler: func(conn *websocket.Conn) {
+			ctx, cancel := context.WithCancel(req.Context())
+			defer cancel()
+			// the client sends nothing, reading only detects the close
+			go func() {
+				var msg []byte
+				for websocket.Message.Receive(conn, &msg) == nil {
+				}
+				cancel()
+			}()
+			err := discr.TailSessions(ctx, params.filter, params.limit, func(tailed discr.TailedSession) error {
+				return websocket.JSON.Send(conn, newTailedSessionJSON(tailed, params.showSession))
+			})
+			if err == context.Canceled {
+				return
+			}
+			if sendErr := websocket.JSON.Send(conn, &tailEndJSON{Reason: err.Error()}); sendErr != nil {
+				countlog.Error("event!tail.err", "err", sendErr)
+			}
+		},
+	}
+	server.ServeHTTP(respWriter, req)
+}
@@ -0,0 +1,124 @@
+package leaf
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+	"github.com/v2pro/quoll/discr"
+	"golang.org/x/net/websocket"
+	"time"
+)
+
+// keepCollecting feeds the session to the subscribers until the returned stop is called,
+// as the tail subscribes some time after the response starts
+func keepCollecting(sessionType string) (stop func()) {
+	session := []byte(`{"CallFromInbound":{"Request":"REQUEST_URI` + sessionType + `\\x0c2"}}`)
+	stopping := make(chan struct{})
+	go func() {
+		for {
+			discr.NewDiscrminator().SceneOf(session)
+			select {
+			case <-stopping:
+				return
+			case <-time.After(5 * time.Millisecond):
+			}
+		}
+	}()
+	return func() {
+		close(stopping)
+	}
+}
+
+func Test_tail_mode(t *testing.T) {
+	should := require.New(t)
+	cases := []struct {
+		url    string
+		header map[string]string
+		mode   string
+	}{
+		{"/tail", nil, "html"},
+		{"/tail?mode=sse", nil, "sse"},
+		{"/tail", map[string]string{"Accept": "text/event-stream"}, "sse"},
+		{"/tail", map[string]string{"Upgrade": "WebSocket"}, "websocket"},
+		{"/tail?mode=html", map[string]string{"Accept": "text/event-stream"}, "html"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", c.url, nil)
+		for k, v := range c.header {
+			req.Header.Set(k, v)
+		}
+		should.Equal(c.mode, tailMode(req), c.url)
+	}
+}
+
+func Test_tail_sse(t *testing.T) {
+	should := require.New(t)
+	server := httptest.NewServer(http.HandlerFunc(tail))
+	defer server.Close()
+	defer keepCollecting("/tail-sse")()
+	resp, err := http.Get(server.URL + "/tail?mode=sse&sessionType=/tail-sse&limit=1&showSession=true")
+	should.Nil(err)
+	defer resp.Body.Close()
+	should.Equal("text/event-stream", resp.Header.Get("Content-Type"))
+	var events []string
+	var data []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			events = append(events, strings.TrimPrefix(line, "event: "))
+		case strings.HasPrefix(line, "data: "):
+			data = append(data, strings.TrimPrefix(line, "data: "))
+		default:
+			should.Equal("", line)
+		}
+	}
+	// the tail ends after more than limit sessions
+	should.Equal([]string{"session", "session", "end"}, events)
+	session := tailedSessionJSON{}
+	should.Nil(jsoniter.Unmarshal([]byte(data[0]), &session))
+	should.Equal("/tail-sse", session.SessionType)
+	should.Contains(session.Session, "REQUEST_URI/tail-sse")
+	end := tailEndJSON{}
+	should.Nil(jsoniter.Unmarshal([]byte(data[2]), &end))
+	should.Equal(discr.ErrTailLimitReached.Error(), end.Reason)
+}
+
+func Test_tail_websocket(t *testing.T) {
+	should := require.New(t)
+	server := httptest.NewServer(http.HandlerFunc(tail))
+	defer server.Close()
+	defer keepCollecting("/tail-ws")()
+	wsUrl := "ws" + strings.TrimPrefix(server.URL, "http") + "/tail?sessionType=/tail-ws&limit=1"
+	conn, err := websocket.Dial(wsUrl, "", server.URL)
+	should.Nil(err)
+	defer conn.Close()
+	for i := 0; i < 2; i++ {
+		session := tailedSessionJSON{}
+		should.Nil(websocket.JSON.Receive(conn, &session))
+		should.Equal("/tail-ws", session.SessionType)
+		should.Equal("", session.Session)
+	}
+	end := tailEndJSON{}
+	should.Nil(websocket.JSON.Receive(conn, &end))
+	should.Equal(discr.ErrTailLimitReached.Error(), end.Reason)
+}
+
+func Test_tail_websocket_origin(t *testing.T) {
+	should := require.New(t)
+	server := httptest.NewServer(http.HandlerFunc(tail))
+	defer server.Close()
+	defer SetTailAllowedOrigins(nil)
+	wsUrl := "ws" + strings.TrimPrefix(server.URL, "http") + "/tail?limit=1"
+	_, err := websocket.Dial(wsUrl, "", "http://evil.example")
+	should.NotNil(err)
+	SetTailAllowedOrigins([]string{"http://dashboard.example"})
+	conn, err := websocket.Dial(wsUrl, "", "http://dashboard.example")
+	should.Nil(err)
+	conn.Close()
+}
@@ -0,0 +1,55 @@
+package timeutil
+
+import "time"
+
+// Clock tells the time and schedules the timers, replaced by FakeClock in tests
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	// Reset changes the timer to expire after d, same as time.Timer the channel is not drained
+	Reset(d time.Duration) bool
+}
+
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+var SystemClock Clock = systemClock{}
+
+type systemClock struct {
+}
+
+func (clock systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (clock systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+func (clock systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+type systemTimer struct {
+	*time.Timer
+}
+
+func (timer systemTimer) C() <-chan time.Time {
+	return timer.Timer.C
+}
+
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (ticker systemTicker) C() <-chan time.Time {
+	return ticker.Ticker.C
+}
@@ -6,18 +6,39 @@ import (
 	"math"
 )
 
+// DefaultShift keeps the compressed timestamp in about 1 microsecond resolution,
+// which covers a bit more than one hour from the base time
+const DefaultShift = 10
+
 func Compress(base time.Time, now time.Time) uint32 {
+	return CompressShifted(base, now, DefaultShift)
+}
+
+func Decompress(base time.Time, compressed uint32) time.Time {
+	return DecompressShifted(base, compressed, DefaultShift)
+}
+
+// ShiftFor returns the smallest shift with which the duration can be compressed
+func ShiftFor(duration time.Duration) uint8 {
+	shift := uint8(DefaultShift)
+	for duration>>shift > math.MaxUint32 {
+		shift++
+	}
+	return shift
+}
+
+func CompressShifted(base time.Time, now time.Time, shift uint8) uint32 {
 	duration := now.Sub(base)
 	if duration < 0 {
 		panic(fmt.Sprintf("can not compress timestamp: %s < %v", now, base))
 	}
-	compressed := duration >> 10
+	compressed := duration >> shift
 	if compressed > math.MaxUint32 {
 		panic(fmt.Sprintf("can not compress timestamp: %v is too large", duration))
 	}
 	return uint32(compressed)
 }
 
-func Decompress(base time.Time, compressed uint32) time.Time {
-	return base.Add(time.Duration(compressed) << 10)
+func DecompressShifted(base time.Time, compressed uint32, shift uint8) time.Time {
+	return base.Add(time.Duration(compressed) << shift)
 }
@@ -0,0 +1,148 @@
+package timeutil
+
+import (
+	"sync"
+	"time"
+)
+
+// FakeClock only moves when told to, the timers and tickers fire as the time passes their deadline
+type FakeClock struct {
+	mutex   sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+type fakeWaiter struct {
+	clock    *FakeClock
+	deadline time.Time
+	period   time.Duration // zero for timer
+	c        chan time.Time
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	clock := &FakeClock{now: now}
+	clock.cond = sync.NewCond(&clock.mutex)
+	return clock
+}
+
+func (clock *FakeClock) Now() time.Time {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	return clock.now
+}
+
+func (clock *FakeClock) Advance(d time.Duration) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	clock.setNow(clock.now.Add(d))
+}
+
+// Set jumps to the time, which can be in the past
+func (clock *FakeClock) Set(now time.Time) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	clock.setNow(now)
+}
+
+func (clock *FakeClock) setNow(now time.Time) {
+	clock.now = now
+	waiters := clock.waiters[:0]
+	for _, waiter := range clock.waiters {
+		if waiter.deadline.After(now) {
+			waiters = append(waiters, waiter)
+			continue
+		}
+		// drop the tick if not received, same as time.Ticker
+		select {
+		case waiter.c <- now:
+		default:
+		}
+		if waiter.period > 0 {
+			for !waiter.deadline.After(now) {
+				waiter.deadline = waiter.deadline.Add(waiter.period)
+			}
+			waiters = append(waiters, waiter)
+		}
+	}
+	clock.waiters = waiters
+	clock.cond.Broadcast()
+}
+
+// BlockUntil waits until there are n timers and tickers not fired or stopped,
+// so that the goroutine under test is known to be waiting
+func (clock *FakeClock) BlockUntil(n int) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	for len(clock.waiters) != n {
+		clock.cond.Wait()
+	}
+}
+
+func (clock *FakeClock) NewTimer(d time.Duration) Timer {
+	return clock.addWaiter(d, 0)
+}
+
+func (clock *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	return fakeTicker{clock.addWaiter(d, d)}
+}
+
+func (clock *FakeClock) addWaiter(d time.Duration, period time.Duration) *fakeWaiter {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	waiter := &fakeWaiter{
+		clock:    clock,
+		deadline: clock.now.Add(d),
+		period:   period,
+		c:        make(chan time.Time, 1),
+	}
+	clock.waiters = append(clock.waiters, waiter)
+	clock.cond.Broadcast()
+	if d <= 0 {
+		clock.setNow(clock.now)
+	}
+	return waiter
+}
+
+func (waiter *fakeWaiter) C() <-chan time.Time {
+	return waiter.c
+}
+
+func (waiter *fakeWaiter) Stop() bool {
+	clock := waiter.clock
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	for i, existing := range clock.waiters {
+		if existing == waiter {
+			clock.waiters = append(clock.waiters[:i], clock.waiters[i+1:]...)
+			clock.cond.Broadcast()
+			return true
+		}
+	}
+	return false
+}
+
+func (waiter *fakeWaiter) Reset(d time.Duration) bool {
+	active := waiter.Stop()
+	clock := waiter.clock
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	waiter.deadline = clock.now.Add(d)
+	clock.waiters = append(clock.waiters, waiter)
+	clock.cond.Broadcast()
+	if d <= 0 {
+		clock.setNow(clock.now)
+	}
+	return active
+}
+
+type fakeTicker struct {
+	*fakeWaiter
+}
+
+func (ticker fakeTicker) Stop() {
+	ticker.fakeWaiter.Stop()
+}
@@ -0,0 +1,139 @@
+package timeutil
+
+import (
+	"testing"
+	"time"
+	"github.com/stretchr/testify/require"
+)
+
+var epoch = time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func fired(c <-chan time.Time) (time.Time, bool) {
+	select {
+	case ts := <-c:
+		return ts, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func Test_fake_clock_advance(t *testing.T) {
+	should := require.New(t)
+	clock := NewFakeClock(epoch)
+	should.Equal(epoch, clock.Now())
+	clock.Advance(time.Minute)
+	should.Equal(epoch.Add(time.Minute), clock.Now())
+	// can be set to the past
+	clock.Set(epoch)
+	should.Equal(epoch, clock.Now())
+}
+
+func Test_fake_clock_timer(t *testing.T) {
+	should := require.New(t)
+	clock := NewFakeClock(epoch)
+	timer := clock.NewTimer(time.Second)
+	clock.Advance(time.Second - time.Nanosecond)
+	_, ok := fired(timer.C())
+	should.False(ok)
+	clock.Advance(time.Nanosecond)
+	ts, ok := fired(timer.C())
+	should.True(ok)
+	should.Equal(epoch.Add(time.Second), ts)
+	// fired only once
+	clock.Advance(time.Hour)
+	_, ok = fired(timer.C())
+	should.False(ok)
+	should.False(timer.Stop())
+	// the timer of zero duration fires at once
+	_, ok = fired(clock.NewTimer(0).C())
+	should.True(ok)
+}
+
+func Test_fake_clock_timer_stop_and_reset(t *testing.T) {
+	should := require.New(t)
+	clock := NewFakeClock(epoch)
+	timer := clock.NewTimer(time.Second)
+	should.True(timer.Stop())
+	should.False(timer.Stop())
+	clock.Advance(time.Second)
+	_, ok := fired(timer.C())
+	should.False(ok)
+	// reset after stopped
+	should.False(timer.Reset(time.Second))
+	clock.Advance(time.Second)
+	ts, ok := fired(timer.C())
+	should.True(ok)
+	should.Equal(epoch.Add(2*time.Second), ts)
+	// reset before fired moves the deadline
+	should.False(timer.Reset(time.Second))
+	should.True(timer.Reset(2 * time.Second))
+	clock.Advance(time.Second)
+	_, ok = fired(timer.C())
+	should.False(ok)
+	clock.Advance(time.Second)
+	_, ok = fired(timer.C())
+	should.True(ok)
+}
+
+func Test_fake_clock_ticker(t *testing.T) {
+	should := require.New(t)
+	clock := NewFakeClock(epoch)
+	ticker := clock.NewTicker(time.Second)
+	clock.Advance(time.Second)
+	ts, ok := fired(ticker.C())
+	should.True(ok)
+	should.Equal(epoch.Add(time.Second), ts)
+	// the ticks not received are dropped
+	clock.Advance(time.Second)
+	clock.Advance(time.Second)
+	ts, ok = fired(ticker.C())
+	should.True(ok)
+	should.Equal(epoch.Add(2*time.Second), ts)
+	_, ok = fired(ticker.C())
+	should.False(ok)
+	// skipping several periods ticks once
+	clock.Advance(10 * time.Second)
+	_, ok = fired(ticker.C())
+	should.True(ok)
+	_, ok = fired(ticker.C())
+	should.False(ok)
+	ticker.Stop()
+	clock.Advance(time.Second)
+	_, ok = fired(ticker.C())
+	should.False(ok)
+	should.Panics(func() {
+		clock.NewTicker(0)
+	})
+}
+
+func Test_fake_clock_block_until(t *testing.T) {
+	should := require.New(t)
+	clock := NewFakeClock(epoch)
+	done := make(chan time.Time)
+	go func() {
+		done <- <-clock.NewTimer(time.Second).C()
+	}()
+	// the goroutine is known to be waiting on the timer
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	select {
+	case ts := <-done:
+		should.Equal(epoch.Add(time.Second), ts)
+	case <-time.After(5 * time.Second):
+		should.Fail("timer not fired")
+	}
+	// the fired timer is no longer waiting
+	clock.BlockUntil(0)
+	stopped := make(chan struct{})
+	timer := clock.NewTimer(time.Second)
+	go func() {
+		clock.BlockUntil(0)
+		close(stopped)
+	}()
+	timer.Stop()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		should.Fail("BlockUntil not woken up by Stop")
+	}
+}